@@ -0,0 +1,373 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/boltdb/bolt"
+	"github.com/gorilla/mux"
+
+	"github.com/codeworkshop-dev/go-tiny-blog/store"
+)
+
+// activityPubContext is the JSON-LD context every outbound ActivityPub
+// document is published with.
+const activityPubContext = "https://www.w3.org/ns/activitystreams"
+
+// actorKeyBucketKey is the fixed key the actor's RSA keypair is stored under
+// in the KEYS bucket. There is only ever one local actor, so a single
+// well-known key is simpler than keying by username.
+const actorKeyBucketKey = "actor"
+
+// actorDocument is the minimal ActivityPub Actor representation this blog
+// publishes at /actor.
+type actorDocument struct {
+	Context           []string       `json:"@context"`
+	ID                string         `json:"id"`
+	Type              string         `json:"type"`
+	PreferredUsername string         `json:"preferredUsername"`
+	Inbox             string         `json:"inbox"`
+	Outbox            string         `json:"outbox"`
+	Followers         string         `json:"followers"`
+	PublicKey         actorPublicKey `json:"publicKey"`
+}
+
+type actorPublicKey struct {
+	ID           string `json:"id"`
+	Owner        string `json:"owner"`
+	PublicKeyPem string `json:"publicKeyPem"`
+}
+
+// activity is a loosely typed ActivityStreams activity. Federation here only
+// needs to branch on Type and read a handful of fields, so we avoid modeling
+// the full vocabulary.
+type activity struct {
+	Context interface{} `json:"@context,omitempty"`
+	ID      string      `json:"id,omitempty"`
+	Type    string      `json:"type"`
+	Actor   string      `json:"actor,omitempty"`
+	Object  interface{} `json:"object,omitempty"`
+	To      []string    `json:"to,omitempty"`
+}
+
+// noteObject is the ActivityStreams representation of a published post.
+type noteObject struct {
+	ID           string   `json:"id"`
+	Type         string   `json:"type"`
+	Published    string   `json:"published"`
+	AttributedTo string   `json:"attributedTo"`
+	Content      string   `json:"content"`
+	URL          string   `json:"url"`
+	To           []string `json:"to"`
+}
+
+// actorURL returns the canonical URL for the local actor.
+func actorURL(cfg config) string {
+	return fmt.Sprintf("https://%s/actor", cfg.Domain)
+}
+
+// webfingerHandler answers /.well-known/webfinger?resource=acct:user@domain
+// with a resource descriptor pointing at the local actor document.
+func webfingerHandler(cfg config) http.HandlerFunc {
+	acct := fmt.Sprintf("acct:%s@%s", cfg.ActorUsername, cfg.Domain)
+	return func(res http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("resource") != acct {
+			res.WriteHeader(http.StatusNotFound)
+			return
+		}
+		res.Header().Set("Content-Type", "application/jrd+json; charset=UTF-8")
+		json.NewEncoder(res).Encode(struct {
+			Subject string `json:"subject"`
+			Links   []struct {
+				Rel  string `json:"rel"`
+				Type string `json:"type"`
+				Href string `json:"href"`
+			} `json:"links"`
+		}{
+			Subject: acct,
+			Links: []struct {
+				Rel  string `json:"rel"`
+				Type string `json:"type"`
+				Href string `json:"href"`
+			}{
+				{Rel: "self", Type: "application/activity+json", Href: actorURL(cfg)},
+			},
+		})
+	}
+}
+
+// actorHandler serves the local actor document, including the public key
+// remote servers use to verify signed activities we send them.
+func actorHandler(db *bolt.DB, cfg config) http.HandlerFunc {
+	return func(res http.ResponseWriter, r *http.Request) {
+		_, pub, err := loadOrCreateActorKey(db)
+		if err != nil {
+			log.Println(err)
+			res.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		id := actorURL(cfg)
+		base := fmt.Sprintf("https://%s", cfg.Domain)
+		doc := actorDocument{
+			Context:           []string{activityPubContext},
+			ID:                id,
+			Type:              "Person",
+			PreferredUsername: cfg.ActorUsername,
+			Inbox:             base + "/inbox",
+			Outbox:            base + "/outbox",
+			Followers:         base + "/followers",
+			PublicKey: actorPublicKey{
+				ID:           id + "#main-key",
+				Owner:        id,
+				PublicKeyPem: pub,
+			},
+		}
+		res.Header().Set("Content-Type", "application/activity+json; charset=UTF-8")
+		json.NewEncoder(res).Encode(doc)
+	}
+}
+
+// inboxHandler accepts incoming activities from remote servers: it verifies
+// the HTTP signature, persists Follow/Undo activities to the FOLLOWERS
+// bucket, and replies Accept to Follow requests.
+func inboxHandler(db *bolt.DB, cfg config) http.HandlerFunc {
+	return func(res http.ResponseWriter, r *http.Request) {
+		_, act, signerID, err := readSignedActivity(r)
+		if err != nil {
+			log.Printf("rejecting inbox activity: %v\n", err)
+			res.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if act.Actor != signerID {
+			log.Printf("rejecting inbox activity: actor %q does not match signer %q\n", act.Actor, signerID)
+			res.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		switch act.Type {
+		case "Follow":
+			if err := addFollower(db, act.Actor); err != nil {
+				log.Println(err)
+				res.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			go deliverAcceptFollow(db, cfg, act)
+		case "Undo":
+			if err := removeFollower(db, act.Actor); err != nil {
+				log.Println(err)
+				res.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+		default:
+			log.Printf("ignoring unsupported inbox activity type %q\n", act.Type)
+		}
+		res.WriteHeader(http.StatusAccepted)
+	}
+}
+
+// outboxHandler publishes the blog's recent posts as an ActivityStreams
+// OrderedCollection of Create activities.
+func outboxHandler(ps store.PostStore, cfg config) http.HandlerFunc {
+	return func(res http.ResponseWriter, r *http.Request) {
+		posts, err := ps.List()
+		if err != nil {
+			res.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		items := make([]activity, 0, len(posts))
+		for slug, post := range posts {
+			items = append(items, createActivityForPost(cfg, slug, post))
+		}
+		res.Header().Set("Content-Type", "application/activity+json; charset=UTF-8")
+		json.NewEncoder(res).Encode(struct {
+			Context      string     `json:"@context"`
+			ID           string     `json:"id"`
+			Type         string     `json:"type"`
+			TotalItems   int        `json:"totalItems"`
+			OrderedItems []activity `json:"orderedItems"`
+		}{
+			Context:      activityPubContext,
+			ID:           fmt.Sprintf("https://%s/outbox", cfg.Domain),
+			Type:         "OrderedCollection",
+			TotalItems:   len(items),
+			OrderedItems: items,
+		})
+	}
+}
+
+// followersHandler publishes the list of remote actors following this blog.
+func followersHandler(db *bolt.DB, cfg config) http.HandlerFunc {
+	return func(res http.ResponseWriter, r *http.Request) {
+		followers, err := listFollowers(db)
+		if err != nil {
+			res.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		res.Header().Set("Content-Type", "application/activity+json; charset=UTF-8")
+		json.NewEncoder(res).Encode(struct {
+			Context      string   `json:"@context"`
+			ID           string   `json:"id"`
+			Type         string   `json:"type"`
+			TotalItems   int      `json:"totalItems"`
+			OrderedItems []string `json:"orderedItems"`
+		}{
+			Context:      activityPubContext,
+			ID:           fmt.Sprintf("https://%s/followers", cfg.Domain),
+			Type:         "OrderedCollection",
+			TotalItems:   len(followers),
+			OrderedItems: followers,
+		})
+	}
+}
+
+// createActivityForPost builds the Create Note activity representing a post,
+// reusing the same markdown/sanitize pipeline the post page renders with.
+func createActivityForPost(cfg config, slug string, post store.Post) activity {
+	postURL := fmt.Sprintf("https://%s/%s", cfg.Domain, slug)
+	note := noteObject{
+		ID:           postURL,
+		Type:         "Note",
+		Published:    post.DatePosted.UTC().Format("2006-01-02T15:04:05Z"),
+		AttributedTo: actorURL(cfg),
+		Content:      string(renderPostHTML([]byte(post.Body))),
+		URL:          postURL,
+		To:           []string{"https://www.w3.org/ns/activitystreams#Public"},
+	}
+	return activity{
+		Context: activityPubContext,
+		ID:      postURL + "/activity",
+		Type:    "Create",
+		Actor:   actorURL(cfg),
+		Object:  note,
+		To:      note.To,
+	}
+}
+
+// loadOrCreateActorKey returns the blog's RSA keypair, generating and
+// persisting one in the KEYS bucket on first use.
+func loadOrCreateActorKey(db *bolt.DB) (*rsa.PrivateKey, string, error) {
+	var privPEM, pubPEM []byte
+	err := db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte("BLOG")).Bucket([]byte("KEYS"))
+		privPEM = b.Get([]byte(actorKeyBucketKey + ".private"))
+		pubPEM = b.Get([]byte(actorKeyBucketKey + ".public"))
+		if privPEM != nil && pubPEM != nil {
+			return nil
+		}
+
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return fmt.Errorf("could not generate actor key: %v", err)
+		}
+		privPEM = pem.EncodeToMemory(&pem.Block{
+			Type:  "RSA PRIVATE KEY",
+			Bytes: x509.MarshalPKCS1PrivateKey(key),
+		})
+		pubBytes, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+		if err != nil {
+			return fmt.Errorf("could not marshal actor public key: %v", err)
+		}
+		pubPEM = pem.EncodeToMemory(&pem.Block{
+			Type:  "PUBLIC KEY",
+			Bytes: pubBytes,
+		})
+		if err := b.Put([]byte(actorKeyBucketKey+".private"), privPEM); err != nil {
+			return fmt.Errorf("could not persist actor private key: %v", err)
+		}
+		if err := b.Put([]byte(actorKeyBucketKey+".public"), pubPEM); err != nil {
+			return fmt.Errorf("could not persist actor public key: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	block, _ := pem.Decode(privPEM)
+	key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, "", fmt.Errorf("could not parse stored actor key: %v", err)
+	}
+	return key, string(pubPEM), nil
+}
+
+// addFollower persists a Follow from a remote actor into the FOLLOWERS
+// bucket, keyed by the follower's actor ID. The remote actor's inbox is
+// fetched before opening the write transaction: BoltDB allows only one
+// writer at a time, and holding that write lock across a blocking outbound
+// HTTP call would stall every other write (post edits, session writes) for
+// as long as the remote server takes to respond.
+func addFollower(db *bolt.DB, actorID string) error {
+	inbox, err := fetchSharedInbox(actorID)
+	if err != nil {
+		inbox = actorID + "/inbox"
+	}
+	return db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte("BLOG")).Bucket([]byte("FOLLOWERS"))
+		return b.Put([]byte(actorID), []byte(inbox))
+	})
+}
+
+// removeFollower deletes a follower, in response to an Undo Follow activity.
+func removeFollower(db *bolt.DB, actorID string) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte("BLOG")).Bucket([]byte("FOLLOWERS")).Delete([]byte(actorID))
+	})
+}
+
+// listFollowers returns the actor IDs of every remote follower.
+func listFollowers(db *bolt.DB) ([]string, error) {
+	var followers []string
+	err := db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte("BLOG")).Bucket([]byte("FOLLOWERS"))
+		c := b.Cursor()
+		for k, _ := c.First(); k != nil; k, _ = c.Next() {
+			followers = append(followers, string(k))
+		}
+		return nil
+	})
+	return followers, err
+}
+
+// listFollowerInboxes returns the shared inbox URL stored for every follower.
+func listFollowerInboxes(db *bolt.DB) ([]string, error) {
+	var inboxes []string
+	err := db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte("BLOG")).Bucket([]byte("FOLLOWERS"))
+		c := b.Cursor()
+		for _, v := c.First(); v != nil; _, v = c.Next() {
+			inboxes = append(inboxes, string(v))
+		}
+		return nil
+	})
+	return inboxes, err
+}
+
+// registerActivityPubRoutes wires the federation endpoints into the router.
+// It is a no-op when federation is disabled via config.
+func registerActivityPubRoutes(r *mux.Router, db *bolt.DB, ps store.PostStore, cfg config) {
+	if cfg.FederationDisabled {
+		return
+	}
+	r.HandleFunc("/.well-known/webfinger", webfingerHandler(cfg)).Methods("GET")
+	r.HandleFunc("/actor", actorHandler(db, cfg)).Methods("GET")
+	r.HandleFunc("/inbox", inboxHandler(db, cfg)).Methods("POST")
+	r.HandleFunc("/outbox", outboxHandler(ps, cfg)).Methods("GET")
+	r.HandleFunc("/followers", followersHandler(db, cfg)).Methods("GET")
+}
+
+// stripFragment removes a trailing "#..." fragment from a URL, as used to go
+// from a publicKey id back to the actor document URL.
+func stripFragment(u string) string {
+	if i := strings.IndexByte(u, '#'); i != -1 {
+		return u[:i]
+	}
+	return u
+}
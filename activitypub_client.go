@@ -0,0 +1,398 @@
+package main
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+// federationHTTPClient is used for every outbound federation request
+// (fetching a remote actor/key, delivering an activity). A bad or slow
+// follower inbox would otherwise be able to hang the single delivery-worker
+// goroutine indefinitely, and with it every handler that blocks on
+// enqueueDelivery once the delivery channel backs up.
+var federationHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+// deliveryJob is one signed activity to be POSTed to a follower's inbox.
+type deliveryJob struct {
+	inboxURL string
+	activity activity
+}
+
+// deliveryQueue decouples handler request/response latency from the outbound
+// HTTP calls federation requires; startDeliveryWorker drains it.
+var deliveryQueue = make(chan deliveryJob, 256)
+
+// startDeliveryWorker runs in the background for the lifetime of the process,
+// signing and delivering queued activities one at a time. Federation delivery
+// is best-effort: a failed delivery is logged and dropped rather than retried,
+// matching how the rest of this app favors simplicity over durability.
+func startDeliveryWorker(db *bolt.DB, cfg config) {
+	go func() {
+		for job := range deliveryQueue {
+			if err := deliverActivity(db, cfg, job.inboxURL, job.activity); err != nil {
+				log.Printf("federation delivery to %s failed: %v\n", job.inboxURL, err)
+			}
+		}
+	}()
+}
+
+// enqueueDelivery schedules an activity for delivery to every current
+// follower's shared inbox. It returns immediately; delivery happens on the
+// background worker.
+func enqueueDelivery(db *bolt.DB, cfg config, act activity) {
+	if cfg.FederationDisabled {
+		return
+	}
+	inboxes, err := listFollowerInboxes(db)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	for _, inbox := range inboxes {
+		deliveryQueue <- deliveryJob{inboxURL: inbox, activity: act}
+	}
+}
+
+// deliverAcceptFollow signs and sends an Accept activity in response to an
+// inbound Follow, as required before the remote server will consider us
+// followed.
+func deliverAcceptFollow(db *bolt.DB, cfg config, follow activity) {
+	inbox, err := fetchSharedInbox(follow.Actor)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	accept := activity{
+		Context: activityPubContext,
+		Type:    "Accept",
+		Actor:   actorURL(cfg),
+		Object:  follow,
+	}
+	if err := deliverActivity(db, cfg, inbox, accept); err != nil {
+		log.Printf("delivering Accept to %s failed: %v\n", inbox, err)
+	}
+}
+
+// deliverActivity signs act with the blog's actor key per draft-cavage-12 and
+// POSTs it to inboxURL.
+func deliverActivity(db *bolt.DB, cfg config, inboxURL string, act activity) error {
+	priv, _, err := loadOrCreateActorKey(db)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(act)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", inboxURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/activity+json")
+	keyID := actorURL(cfg) + "#main-key"
+	if err := signRequest(req, keyID, priv, body); err != nil {
+		return err
+	}
+	resp, err := federationHTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("remote inbox returned %s", resp.Status)
+	}
+	return nil
+}
+
+// signRequest adds Date, Digest, and Signature headers to req following
+// draft-cavage-12 HTTP Signatures, covering (request-target), host, date and
+// digest.
+func signRequest(req *http.Request, keyID string, priv *rsa.PrivateKey, body []byte) error {
+	if req.Header.Get("Date") == "" {
+		req.Header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+	}
+	digest := sha256.Sum256(body)
+	req.Header.Set("Digest", "SHA-256="+base64.StdEncoding.EncodeToString(digest[:]))
+	if req.Host == "" {
+		req.Host = req.URL.Host
+	}
+
+	signedHeaders := []string{"(request-target)", "host", "date", "digest"}
+	signingString := buildSigningString(req, signedHeaders)
+
+	hashed := sha256.Sum256([]byte(signingString))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, priv, crypto.SHA256, hashed[:])
+	if err != nil {
+		return fmt.Errorf("could not sign request: %v", err)
+	}
+
+	req.Header.Set("Signature", fmt.Sprintf(
+		`keyId="%s",algorithm="rsa-sha256",headers="%s",signature="%s"`,
+		keyID, strings.Join(signedHeaders, " "), base64.StdEncoding.EncodeToString(sig),
+	))
+	return nil
+}
+
+// buildSigningString reconstructs the draft-cavage-12 signing string for the
+// given (lowercased) header names, pulling "(request-target)" from the
+// request's method and path rather than an actual header.
+func buildSigningString(req *http.Request, headers []string) string {
+	lines := make([]string, len(headers))
+	for i, h := range headers {
+		switch h {
+		case "(request-target)":
+			lines[i] = fmt.Sprintf("(request-target): %s %s", strings.ToLower(req.Method), req.URL.RequestURI())
+		case "host":
+			lines[i] = "host: " + req.Host
+		default:
+			lines[i] = strings.ToLower(h) + ": " + req.Header.Get(h)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// parsedSignature is the decoded content of an inbound Signature header.
+type parsedSignature struct {
+	keyID     string
+	algorithm string
+	headers   []string
+	signature []byte
+}
+
+// parseSignatureHeader decodes the key="value" pairs of a draft-cavage-12
+// Signature header.
+func parseSignatureHeader(header string) (*parsedSignature, error) {
+	sig := &parsedSignature{algorithm: "rsa-sha256", headers: []string{"date"}}
+	for _, part := range strings.Split(header, ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		key := kv[0]
+		val := strings.Trim(kv[1], `"`)
+		switch key {
+		case "keyId":
+			sig.keyID = val
+		case "algorithm":
+			sig.algorithm = val
+		case "headers":
+			sig.headers = strings.Fields(val)
+		case "signature":
+			decoded, err := base64.StdEncoding.DecodeString(val)
+			if err != nil {
+				return nil, fmt.Errorf("could not decode signature: %v", err)
+			}
+			sig.signature = decoded
+		}
+	}
+	if sig.keyID == "" || sig.signature == nil {
+		return nil, fmt.Errorf("signature header missing keyId or signature")
+	}
+	return sig, nil
+}
+
+// validateFederationURL rejects URLs this server should never fetch on
+// behalf of a remote, unauthenticated caller: keyId and actor values arrive
+// straight from an inbound Signature header or activity body, so without
+// this check a caller could point either at an internal or loopback
+// address (e.g. cloud metadata) and use this server as an SSRF proxy.
+func validateFederationURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid federation URL: %v", err)
+	}
+	if u.Scheme != "https" {
+		return fmt.Errorf("refusing non-https federation URL: %s", rawURL)
+	}
+	host := u.Hostname()
+	if host == "" {
+		return fmt.Errorf("federation URL has no host: %s", rawURL)
+	}
+	ips, err := net.LookupIP(host)
+	if err != nil {
+		return fmt.Errorf("could not resolve %s: %v", host, err)
+	}
+	for _, ip := range ips {
+		if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() {
+			return fmt.Errorf("refusing federation URL resolving to a private address: %s", rawURL)
+		}
+	}
+	return nil
+}
+
+// fetchActorPublicKey dereferences an actor (or key) id and returns its RSA
+// public key, as published on the actor document's publicKey field.
+func fetchActorPublicKey(keyID string) (*rsa.PublicKey, error) {
+	target := stripFragment(keyID)
+	if err := validateFederationURL(target); err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest("GET", target, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/activity+json")
+	resp, err := federationHTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var doc actorDocument
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("could not decode remote actor: %v", err)
+	}
+	block, _ := pem.Decode([]byte(doc.PublicKey.PublicKeyPem))
+	if block == nil {
+		return nil, fmt.Errorf("remote actor published no usable public key")
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse remote public key: %v", err)
+	}
+	rsaPub, ok := pub.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("remote public key is not RSA")
+	}
+	return rsaPub, nil
+}
+
+// fetchSharedInbox dereferences a remote actor id and returns the inbox its
+// activities should be delivered to, preferring the shared inbox when the
+// actor publishes one.
+func fetchSharedInbox(actorID string) (string, error) {
+	if err := validateFederationURL(actorID); err != nil {
+		return "", err
+	}
+	req, err := http.NewRequest("GET", actorID, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/activity+json")
+	resp, err := federationHTTPClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var doc struct {
+		Inbox     string `json:"inbox"`
+		Endpoints struct {
+			SharedInbox string `json:"sharedInbox"`
+		} `json:"endpoints"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return "", fmt.Errorf("could not decode remote actor: %v", err)
+	}
+	if doc.Endpoints.SharedInbox != "" {
+		return doc.Endpoints.SharedInbox, nil
+	}
+	if doc.Inbox != "" {
+		return doc.Inbox, nil
+	}
+	return "", fmt.Errorf("remote actor %s published no inbox", actorID)
+}
+
+// requiredSignedHeaders are the headers a signature must cover for a request
+// with a body: without "(request-target)" the signature doesn't bind to the
+// method/path being requested, and without "digest" it doesn't bind to the
+// body, so a signature omitting either would verify successfully regardless
+// of what the caller actually sent.
+var requiredSignedHeaders = []string{"(request-target)", "digest"}
+
+// readSignedActivity reads and verifies an inbound request's HTTP signature,
+// then decodes the body as an ActivityStreams activity. It returns the actor
+// id the signature actually authenticates (the owner of sig.keyID) so
+// callers can check it against claims made in the activity body itself.
+func readSignedActivity(r *http.Request) ([]byte, activity, string, error) {
+	var act activity
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, 1048576))
+	if err != nil {
+		return nil, act, "", err
+	}
+	r.Body.Close()
+
+	sigHeader := r.Header.Get("Signature")
+	if sigHeader == "" {
+		return nil, act, "", fmt.Errorf("missing Signature header")
+	}
+	sig, err := parseSignatureHeader(sigHeader)
+	if err != nil {
+		return nil, act, "", err
+	}
+	if len(body) > 0 {
+		for _, required := range requiredSignedHeaders {
+			if !containsString(sig.headers, required) {
+				return nil, act, "", fmt.Errorf("signature does not cover required header %q", required)
+			}
+		}
+		if err := verifyDigestHeader(r.Header.Get("Digest"), body); err != nil {
+			return nil, act, "", err
+		}
+	}
+	pub, err := fetchActorPublicKey(sig.keyID)
+	if err != nil {
+		return nil, act, "", fmt.Errorf("could not resolve signer key: %v", err)
+	}
+	signingString := buildSigningString(r, sig.headers)
+	hashed := sha256.Sum256([]byte(signingString))
+	if err := rsa.VerifyPKCS1v15(pub, crypto.SHA256, hashed[:], sig.signature); err != nil {
+		return nil, act, "", fmt.Errorf("signature verification failed: %v", err)
+	}
+
+	if err := json.Unmarshal(body, &act); err != nil {
+		return nil, act, "", fmt.Errorf("could not decode activity: %v", err)
+	}
+	return body, act, stripFragment(sig.keyID), nil
+}
+
+// verifyDigestHeader checks that digestHeader (a draft-cavage "Digest"
+// header, e.g. "SHA-256=<base64>") matches the SHA-256 hash of body. The
+// HTTP signature only covers the literal header strings, not the body
+// itself, so listing "digest" among the signed headers only binds the
+// signature to *this check actually passing* — without it, a request could
+// be replayed with a swapped-out body and still verify.
+func verifyDigestHeader(digestHeader string, body []byte) error {
+	const prefix = "SHA-256="
+	if !strings.HasPrefix(digestHeader, prefix) {
+		return fmt.Errorf("missing or unsupported Digest header")
+	}
+	want, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(digestHeader, prefix))
+	if err != nil {
+		return fmt.Errorf("could not decode Digest header: %v", err)
+	}
+	got := sha256.Sum256(body)
+	if !hmac.Equal(got[:], want) {
+		return fmt.Errorf("digest does not match request body")
+	}
+	return nil
+}
+
+// containsString reports whether needle appears in haystack.
+func containsString(haystack []string, needle string) bool {
+	for _, s := range haystack {
+		if s == needle {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestBuildSigningString(t *testing.T) {
+	req, err := http.NewRequest("POST", "https://example.com/inbox", nil)
+	if err != nil {
+		t.Fatalf("could not build request: %v", err)
+	}
+	req.Host = "example.com"
+	req.Header.Set("Date", "Wed, 01 Jan 2025 00:00:00 GMT")
+	req.Header.Set("Digest", "SHA-256=abc123")
+
+	got := buildSigningString(req, []string{"(request-target)", "host", "date", "digest"})
+	want := "(request-target): post /inbox\n" +
+		"host: example.com\n" +
+		"date: Wed, 01 Jan 2025 00:00:00 GMT\n" +
+		"digest: SHA-256=abc123"
+
+	if got != want {
+		t.Errorf("buildSigningString() =\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestParseSignatureHeader(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  string
+		want    *parsedSignature
+		wantErr bool
+	}{
+		{
+			name:   "full header",
+			header: `keyId="https://remote.example/actor#main-key",algorithm="rsa-sha256",headers="(request-target) host date digest",signature="c2lnbmF0dXJl"`,
+			want: &parsedSignature{
+				keyID:     "https://remote.example/actor#main-key",
+				algorithm: "rsa-sha256",
+				headers:   []string{"(request-target)", "host", "date", "digest"},
+				signature: []byte("signature"),
+			},
+		},
+		{
+			name:   "missing headers field defaults to date",
+			header: `keyId="https://remote.example/actor#main-key",algorithm="rsa-sha256",signature="c2lnbmF0dXJl"`,
+			want: &parsedSignature{
+				keyID:     "https://remote.example/actor#main-key",
+				algorithm: "rsa-sha256",
+				headers:   []string{"date"},
+				signature: []byte("signature"),
+			},
+		},
+		{
+			name:    "missing keyId is rejected",
+			header:  `algorithm="rsa-sha256",signature="c2lnbmF0dXJl"`,
+			wantErr: true,
+		},
+		{
+			name:    "missing signature is rejected",
+			header:  `keyId="https://remote.example/actor#main-key"`,
+			wantErr: true,
+		},
+		{
+			name:    "undecodable signature is rejected",
+			header:  `keyId="https://remote.example/actor#main-key",signature="not-valid-base64!"`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseSignatureHeader(tt.header)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseSignatureHeader(%q) returned no error, want one", tt.header)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseSignatureHeader(%q) returned error: %v", tt.header, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseSignatureHeader(%q) = %+v, want %+v", tt.header, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyDigestHeader(t *testing.T) {
+	body := []byte(`{"type":"Follow"}`)
+	sum := sha256.Sum256(body)
+	validDigest := "SHA-256=" + base64.StdEncoding.EncodeToString(sum[:])
+
+	tests := []struct {
+		name    string
+		digest  string
+		body    []byte
+		wantErr bool
+	}{
+		{name: "matching digest", digest: validDigest, body: body},
+		{name: "swapped body no longer matches", digest: validDigest, body: []byte(`{"type":"Undo"}`), wantErr: true},
+		{name: "unsupported algorithm is rejected", digest: "SHA-512=anything", body: body, wantErr: true},
+		{name: "undecodable digest is rejected", digest: "SHA-256=not-valid-base64!", body: body, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifyDigestHeader(tt.digest, tt.body)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("verifyDigestHeader(%q, %q) error = %v, wantErr %v", tt.digest, tt.body, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateFederationURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		url     string
+		wantErr bool
+	}{
+		{name: "http is rejected", url: "http://example.com/actor", wantErr: true},
+		{name: "loopback is rejected", url: "https://127.0.0.1/actor", wantErr: true},
+		{name: "link-local is rejected", url: "https://169.254.169.254/actor", wantErr: true},
+		{name: "invalid url is rejected", url: "://not-a-url", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateFederationURL(tt.url)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateFederationURL(%q) error = %v, wantErr %v", tt.url, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/gorilla/securecookie"
+)
+
+const sessionCookieName = "session"
+const sessionMaxAge = 24 * time.Hour
+
+const sessionHashKeyName = "session-hash-key"
+const sessionBlockKeyName = "session-block-key"
+
+// SessionManager issues and validates the signed, encrypted cookies that
+// back logged-in sessions and CSRF tokens.
+type SessionManager struct {
+	sc *securecookie.SecureCookie
+}
+
+// NewSessionManager builds a SessionManager from a persisted hash/block key
+// pair (see LoadOrCreateSessionKeys).
+func NewSessionManager(hashKey, blockKey []byte) *SessionManager {
+	return &SessionManager{sc: securecookie.New(hashKey, blockKey)}
+}
+
+// LoadOrCreateSessionKeys returns the keys used to sign and encrypt session
+// and CSRF cookies, generating and persisting them in the KEYS bucket on
+// first use so sessions survive a restart.
+func LoadOrCreateSessionKeys(db *bolt.DB) (hashKey, blockKey []byte, err error) {
+	err = db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte("BLOG")).Bucket([]byte("KEYS"))
+		hashKey = b.Get([]byte(sessionHashKeyName))
+		blockKey = b.Get([]byte(sessionBlockKeyName))
+		if hashKey != nil && blockKey != nil {
+			return nil
+		}
+
+		hashKey = securecookie.GenerateRandomKey(64)
+		blockKey = securecookie.GenerateRandomKey(32)
+		if hashKey == nil || blockKey == nil {
+			return fmt.Errorf("could not generate session keys")
+		}
+		if err := b.Put([]byte(sessionHashKeyName), hashKey); err != nil {
+			return fmt.Errorf("could not persist session hash key: %v", err)
+		}
+		if err := b.Put([]byte(sessionBlockKeyName), blockKey); err != nil {
+			return fmt.Errorf("could not persist session block key: %v", err)
+		}
+		return nil
+	})
+	return hashKey, blockKey, err
+}
+
+type sessionValue struct {
+	Username string
+}
+
+// SetSession logs in as username by issuing a signed session cookie.
+func (sm *SessionManager) SetSession(res http.ResponseWriter, username string) error {
+	encoded, err := sm.sc.Encode(sessionCookieName, sessionValue{Username: username})
+	if err != nil {
+		return err
+	}
+	http.SetCookie(res, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    encoded,
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+		MaxAge:   int(sessionMaxAge.Seconds()),
+	})
+	return nil
+}
+
+// ClearSession logs the visitor out.
+func (sm *SessionManager) ClearSession(res http.ResponseWriter) {
+	http.SetCookie(res, &http.Cookie{
+		Name:   sessionCookieName,
+		Value:  "",
+		Path:   "/",
+		MaxAge: -1,
+	})
+}
+
+// Username returns the logged-in username carried by the request's session
+// cookie, and whether one was present.
+func (sm *SessionManager) Username(r *http.Request) (string, bool) {
+	cookie, err := r.Cookie(sessionCookieName)
+	if err != nil {
+		return "", false
+	}
+	var value sessionValue
+	if err := sm.sc.Decode(sessionCookieName, cookie.Value, &value); err != nil {
+		return "", false
+	}
+	return value.Username, value.Username != ""
+}
+
+// RequireAuth wraps a handler, rejecting requests that don't carry a valid
+// session cookie.
+func (sm *SessionManager) RequireAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, r *http.Request) {
+		if _, ok := sm.Username(r); !ok {
+			http.Error(res, "authentication required", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(res, r)
+	})
+}
@@ -0,0 +1,91 @@
+// Package auth provides the blog's user credentials, session cookies, and
+// CSRF protection.
+package auth
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/boltdb/bolt"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// usersBucket is the BoltDB bucket, nested under BLOG, that stores
+// bcrypt-hashed credentials keyed by username.
+const usersBucket = "USERS"
+
+// ErrInvalidCredentials is returned by Authenticate when the username does
+// not exist or the password does not match.
+var ErrInvalidCredentials = errors.New("invalid username or password")
+
+// CreateUser hashes password and stores it under username, overwriting any
+// existing credentials for that username.
+func CreateUser(db *bolt.DB, username, password string) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("could not hash password: %v", err)
+	}
+	return db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte("BLOG")).Bucket([]byte(usersBucket))
+		return bucket.Put([]byte(username), hash)
+	})
+}
+
+// UserExists reports whether username already has credentials stored.
+func UserExists(db *bolt.DB, username string) (bool, error) {
+	var exists bool
+	err := db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte("BLOG")).Bucket([]byte(usersBucket))
+		exists = bucket.Get([]byte(username)) != nil
+		return nil
+	})
+	return exists, err
+}
+
+// Authenticate checks password against the stored hash for username,
+// returning ErrInvalidCredentials if the username is unknown or the
+// password is wrong.
+func Authenticate(db *bolt.DB, username, password string) error {
+	var hash []byte
+	err := db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte("BLOG")).Bucket([]byte(usersBucket))
+		hash = bucket.Get([]byte(username))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if hash == nil {
+		return ErrInvalidCredentials
+	}
+	if err := bcrypt.CompareHashAndPassword(hash, []byte(password)); err != nil {
+		return ErrInvalidCredentials
+	}
+	return nil
+}
+
+// EnsureAdmin creates the initial admin user from username/password if no
+// user with that name exists yet. It is a no-op when either argument is
+// empty, so deployments that haven't set the bootstrap env vars just start
+// without one.
+func EnsureAdmin(db *bolt.DB, username, password string) error {
+	if username == "" || password == "" {
+		return nil
+	}
+	exists, err := UserExists(db, username)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+	return CreateUser(db, username, password)
+}
+
+// CreateUsersBucket idempotently creates the USERS bucket. Called from
+// setupDB alongside the blog's other subsystem buckets.
+func CreateUsersBucket(tx *bolt.Tx) error {
+	root := tx.Bucket([]byte("BLOG"))
+	_, err := root.CreateBucketIfNotExists([]byte(usersBucket))
+	return err
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"html/template"
+	"net/http"
+
+	"github.com/boltdb/bolt"
+
+	"github.com/codeworkshop-dev/go-tiny-blog/auth"
+)
+
+// LoginPageData is the data required to render the login form template.
+type LoginPageData struct {
+	SiteMetaData SiteMetaData
+	Error        string
+}
+
+// loginPageHandler serves the login form.
+func loginPageHandler(t *template.Template) http.HandlerFunc {
+	return func(res http.ResponseWriter, r *http.Request) {
+		res.Header().Set("Content-Type", "text/html; charset=UTF-8")
+		res.WriteHeader(http.StatusOK)
+		t.Execute(res, LoginPageData{SiteMetaData: siteMetaData})
+	}
+}
+
+// loginHandler authenticates the submitted username/password and, on
+// success, starts a session and redirects to the home page.
+func loginHandler(db *bolt.DB, sm *auth.SessionManager, t *template.Template) http.HandlerFunc {
+	return func(res http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			res.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		username := r.FormValue("username")
+		password := r.FormValue("password")
+
+		if err := auth.Authenticate(db, username, password); err != nil {
+			res.Header().Set("Content-Type", "text/html; charset=UTF-8")
+			res.WriteHeader(http.StatusUnauthorized)
+			t.Execute(res, LoginPageData{SiteMetaData: siteMetaData, Error: "Invalid username or password."})
+			return
+		}
+		if err := sm.SetSession(res, username); err != nil {
+			res.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(res, r, "/", http.StatusSeeOther)
+	}
+}
+
+// logoutHandler clears the visitor's session and redirects to the home page.
+func logoutHandler(sm *auth.SessionManager) http.HandlerFunc {
+	return func(res http.ResponseWriter, r *http.Request) {
+		sm.ClearSession(res)
+		http.Redirect(res, r, "/", http.StatusSeeOther)
+	}
+}
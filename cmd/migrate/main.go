@@ -0,0 +1,79 @@
+// Command migrate copies every post from one go-tiny-blog storage backend to
+// another, e.g. moving a BoltDB-backed blog onto flat markdown files:
+//
+//	migrate -from bolt -from-path tinyblog.db -to file -to-path content
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+
+	"github.com/boltdb/bolt"
+
+	"github.com/codeworkshop-dev/go-tiny-blog/store"
+)
+
+func main() {
+	fromKind := flag.String("from", "bolt", "source backend: bolt, file, or sqlite")
+	fromPath := flag.String("from-path", "tinyblog.db", "path for the source backend")
+	toKind := flag.String("to", "file", "destination backend: bolt, file, or sqlite")
+	toPath := flag.String("to-path", "content", "path for the destination backend")
+	flag.Parse()
+
+	from, closeFrom, err := openStore(*fromKind, *fromPath)
+	if err != nil {
+		log.Fatalf("could not open source store: %v", err)
+	}
+	defer closeFrom()
+
+	to, closeTo, err := openStore(*toKind, *toPath)
+	if err != nil {
+		log.Fatalf("could not open destination store: %v", err)
+	}
+	defer closeTo()
+
+	posts, err := from.List()
+	if err != nil {
+		log.Fatalf("could not list source posts: %v", err)
+	}
+
+	for slug, post := range posts {
+		if err := to.Upsert(post, slug); err != nil {
+			log.Fatalf("could not migrate post %q: %v", slug, err)
+		}
+	}
+	log.Printf("Migrated %d posts from %s (%s) to %s (%s).\n", len(posts), *fromKind, *fromPath, *toKind, *toPath)
+}
+
+// openStore opens the named backend and returns a function to clean up any
+// resources it holds.
+func openStore(kind, path string) (store.PostStore, func(), error) {
+	switch kind {
+	case "bolt":
+		db, err := bolt.Open(path, 0600, nil)
+		if err != nil {
+			return nil, nil, fmt.Errorf("could not open bolt database: %v", err)
+		}
+		ps, err := store.NewBoltStore(db)
+		if err != nil {
+			db.Close()
+			return nil, nil, err
+		}
+		return ps, func() { db.Close() }, nil
+	case "file":
+		ps, err := store.NewFileStore(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		return ps, func() {}, nil
+	case "sqlite":
+		ps, err := store.NewSQLiteStore(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		return ps, func() { ps.Close() }, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown backend %q", kind)
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import "os"
+
+// config holds runtime settings that used to be hardcoded constants. It is
+// loaded once from the environment at startup in main.
+type config struct {
+	// Domain is the public hostname this instance is served from, e.g.
+	// "blog.example.com". It is used to build canonical URLs for
+	// federation (actor IDs, webfinger, outbox items, ...).
+	Domain string
+	// ActorUsername is the handle federated posts are published under,
+	// e.g. "blog" for an actor addressed as acct:blog@example.com.
+	ActorUsername string
+	// FederationDisabled turns off the activitypub subsystem entirely
+	// (no outbound deliveries, federation endpoints 404).
+	FederationDisabled bool
+	// StorageBackend selects the PostStore implementation: "bolt" (default),
+	// "file", or "sqlite".
+	StorageBackend string
+	// ContentDir is where the "file" backend reads and writes `.md` posts.
+	ContentDir string
+	// SQLitePath is where the "sqlite" backend's database file lives.
+	SQLitePath string
+	// AdminUsername and AdminPassword bootstrap the initial user on first
+	// startup (see setupDB). Left empty, no admin user is created.
+	AdminUsername string
+	AdminPassword string
+}
+
+// loadConfig reads configuration from the environment, falling back to
+// sane defaults for local development.
+func loadConfig() config {
+	return config{
+		Domain:             envOrDefault("BLOG_DOMAIN", "localhost:8000"),
+		ActorUsername:      envOrDefault("BLOG_ACTOR_USERNAME", "blog"),
+		FederationDisabled: os.Getenv("BLOG_DISABLE_FEDERATION") == "true",
+		StorageBackend:     envOrDefault("BLOG_STORAGE_BACKEND", "bolt"),
+		ContentDir:         envOrDefault("BLOG_CONTENT_DIR", "content"),
+		SQLitePath:         envOrDefault("BLOG_SQLITE_PATH", "tinyblog-posts.db"),
+		AdminUsername:      os.Getenv("BLOG_ADMIN_USERNAME"),
+		AdminPassword:      os.Getenv("BLOG_ADMIN_PASSWORD"),
+	}
+}
+
+// envOrDefault returns the named environment variable, or def if it is unset
+// or empty.
+func envOrDefault(name, def string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return def
+}
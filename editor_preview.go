@@ -0,0 +1,83 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// previewMaxMessageBytes caps how large a single preview message may be, so a
+// connected client can't force the server to buffer an unbounded amount of
+// memory per WebSocket frame.
+const previewMaxMessageBytes = 1 << 20
+
+// previewRateLimiter caps how often a single connection may trigger a
+// render, so a pathological client (e.g. a paste followed by rapid-fire
+// keystrokes) can't force the server into a render loop.
+type previewRateLimiter struct {
+	interval time.Duration
+	last     time.Time
+}
+
+func newPreviewRateLimiter(interval time.Duration) *previewRateLimiter {
+	return &previewRateLimiter{interval: interval}
+}
+
+// Allow reports whether a render may proceed right now.
+func (l *previewRateLimiter) Allow() bool {
+	now := time.Now()
+	if now.Sub(l.last) < l.interval {
+		return false
+	}
+	l.last = now
+	return true
+}
+
+// editorPreviewHandler answers the /editor/preview WebSocket endpoint used by
+// createPostPageHandler and editPostPageHandler's pages: the client sends the
+// current markdown body as a text message on every keystroke, and the server
+// replies with the same sanitized HTML that getPostHandler renders for a
+// published post, via the shared renderPostHTML helper. The route is wrapped
+// with sm.RequireAuth in newRouter, and CheckOrigin here rejects handshakes
+// whose Origin header doesn't match cfg.Domain, since the auth cookie alone
+// doesn't stop a cross-site page from opening the WebSocket. The scheme is
+// deliberately not checked: this server always listens on plain HTTP (see
+// main's srv.ListenAndServe) and relies on a TLS-terminating proxy in
+// production, so the Origin a legitimate same-site request arrives with
+// depends on deployment, not on anything this process controls.
+func editorPreviewHandler(cfg config) http.HandlerFunc {
+	upgrader := websocket.Upgrader{
+		ReadBufferSize:  4096,
+		WriteBufferSize: 4096,
+		CheckOrigin: func(r *http.Request) bool {
+			origin, err := url.Parse(r.Header.Get("Origin"))
+			return err == nil && origin.Host == cfg.Domain
+		},
+	}
+	return func(res http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(res, r, nil)
+		if err != nil {
+			log.Println(err)
+			return
+		}
+		defer conn.Close()
+		conn.SetReadLimit(previewMaxMessageBytes)
+
+		limiter := newPreviewRateLimiter(100 * time.Millisecond)
+		for {
+			_, body, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if !limiter.Allow() {
+				continue
+			}
+			if err := conn.WriteMessage(websocket.TextMessage, []byte(renderPostHTML(body))); err != nil {
+				return
+			}
+		}
+	}
+}
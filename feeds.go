@@ -0,0 +1,305 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/codeworkshop-dev/go-tiny-blog/store"
+)
+
+// rssFeed is the root element of an RSS 2.0 document.
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title       string    `xml:"title"`
+	Link        string    `xml:"link"`
+	Description string    `xml:"description"`
+	Items       []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string `xml:"title"`
+	Link        string `xml:"link"`
+	GUID        string `xml:"guid"`
+	PubDate     string `xml:"pubDate"`
+	Author      string `xml:"author,omitempty"`
+	Description string `xml:"description"`
+}
+
+// atomFeed is the root element of an Atom 1.0 document.
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomEntry struct {
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Link    atomLink    `xml:"link"`
+	Author  atomAuthor  `xml:"author"`
+	Content atomContent `xml:"content"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+// jsonFeed follows the JSON Feed 1.1 spec (jsonfeed.org).
+type jsonFeed struct {
+	Version     string         `json:"version"`
+	Title       string         `json:"title"`
+	HomePageURL string         `json:"home_page_url"`
+	FeedURL     string         `json:"feed_url"`
+	Items       []jsonFeedItem `json:"items"`
+}
+
+type jsonFeedItem struct {
+	ID            string          `json:"id"`
+	URL           string          `json:"url"`
+	Title         string          `json:"title"`
+	ContentHTML   string          `json:"content_html"`
+	DatePublished string          `json:"date_published"`
+	Author        *jsonFeedAuthor `json:"author,omitempty"`
+}
+
+type jsonFeedAuthor struct {
+	Name string `json:"name"`
+}
+
+// sitemapURLSet is the root element of a sitemap.xml document.
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"urlset"`
+	Xmlns   string       `xml:"xmlns,attr"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+// postURL returns the canonical URL for a post, given its slug.
+func postURL(cfg config, slug string) string {
+	return fmt.Sprintf("https://%s/%s", cfg.Domain, slug)
+}
+
+// sortedPosts returns posts ordered newest first.
+func sortedPosts(posts store.PostMap) []store.Post {
+	list := make([]store.Post, 0, len(posts))
+	for _, post := range posts {
+		list = append(list, post)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].DatePosted.After(list[j].DatePosted) })
+	return list
+}
+
+// latestPostDate returns the most recent DatePosted across posts, the zero
+// time if there are none.
+func latestPostDate(posts store.PostMap) time.Time {
+	var latest time.Time
+	for _, post := range posts {
+		if post.DatePosted.After(latest) {
+			latest = post.DatePosted
+		}
+	}
+	return latest
+}
+
+// writeFeedCacheHeaders sets an ETag/Last-Modified derived from lastMod and
+// reports whether the request's conditional headers mean the client's copy
+// is still fresh, so the handler can reply 304 without re-rendering the feed.
+func writeFeedCacheHeaders(res http.ResponseWriter, r *http.Request, lastMod time.Time) bool {
+	etag := fmt.Sprintf(`"%d"`, lastMod.UnixNano())
+	res.Header().Set("ETag", etag)
+	res.Header().Set("Last-Modified", lastMod.UTC().Format(http.TimeFormat))
+
+	if r.Header.Get("If-None-Match") == etag {
+		return true
+	}
+	if since := r.Header.Get("If-Modified-Since"); since != "" {
+		if t, err := time.Parse(http.TimeFormat, since); err == nil && !lastMod.After(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// feedRSSHandler answers GET /feed.rss with an RSS 2.0 feed of every post.
+func feedRSSHandler(ps store.PostStore, cfg config) http.HandlerFunc {
+	return func(res http.ResponseWriter, r *http.Request) {
+		posts, err := ps.List()
+		if err != nil {
+			res.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if writeFeedCacheHeaders(res, r, latestPostDate(posts)) {
+			res.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		items := make([]rssItem, 0, len(posts))
+		for _, post := range sortedPosts(posts) {
+			link := postURL(cfg, post.Slug)
+			items = append(items, rssItem{
+				Title:       post.Title,
+				Link:        link,
+				GUID:        link,
+				PubDate:     post.DatePosted.UTC().Format(time.RFC1123Z),
+				Author:      post.Author,
+				Description: string(renderPostHTML([]byte(post.Body))),
+			})
+		}
+		feed := rssFeed{
+			Version: "2.0",
+			Channel: rssChannel{
+				Title:       siteMetaData.Title,
+				Link:        fmt.Sprintf("https://%s", cfg.Domain),
+				Description: siteMetaData.Description,
+				Items:       items,
+			},
+		}
+
+		res.Header().Set("Content-Type", "application/rss+xml; charset=UTF-8")
+		res.Write([]byte(xml.Header))
+		xml.NewEncoder(res).Encode(feed)
+	}
+}
+
+// feedAtomHandler answers GET /feed.atom with an Atom 1.0 feed of every post.
+func feedAtomHandler(ps store.PostStore, cfg config) http.HandlerFunc {
+	return func(res http.ResponseWriter, r *http.Request) {
+		posts, err := ps.List()
+		if err != nil {
+			res.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		lastMod := latestPostDate(posts)
+		if writeFeedCacheHeaders(res, r, lastMod) {
+			res.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		entries := make([]atomEntry, 0, len(posts))
+		for _, post := range sortedPosts(posts) {
+			link := postURL(cfg, post.Slug)
+			entries = append(entries, atomEntry{
+				Title:   post.Title,
+				ID:      link,
+				Updated: post.DatePosted.UTC().Format(time.RFC3339),
+				Link:    atomLink{Rel: "alternate", Href: link},
+				Author:  atomAuthor{Name: post.Author},
+				Content: atomContent{Type: "html", Body: string(renderPostHTML([]byte(post.Body)))},
+			})
+		}
+		feed := atomFeed{
+			Title:   siteMetaData.Title,
+			ID:      fmt.Sprintf("https://%s/", cfg.Domain),
+			Updated: lastMod.UTC().Format(time.RFC3339),
+			Links: []atomLink{
+				{Rel: "self", Href: fmt.Sprintf("https://%s/feed.atom", cfg.Domain)},
+			},
+			Entries: entries,
+		}
+
+		res.Header().Set("Content-Type", "application/atom+xml; charset=UTF-8")
+		res.Write([]byte(xml.Header))
+		xml.NewEncoder(res).Encode(feed)
+	}
+}
+
+// feedJSONHandler answers GET /feed.json with a JSON Feed of every post.
+func feedJSONHandler(ps store.PostStore, cfg config) http.HandlerFunc {
+	return func(res http.ResponseWriter, r *http.Request) {
+		posts, err := ps.List()
+		if err != nil {
+			res.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if writeFeedCacheHeaders(res, r, latestPostDate(posts)) {
+			res.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		items := make([]jsonFeedItem, 0, len(posts))
+		for _, post := range sortedPosts(posts) {
+			var author *jsonFeedAuthor
+			if post.Author != "" {
+				author = &jsonFeedAuthor{Name: post.Author}
+			}
+			link := postURL(cfg, post.Slug)
+			items = append(items, jsonFeedItem{
+				ID:            link,
+				URL:           link,
+				Title:         post.Title,
+				ContentHTML:   string(renderPostHTML([]byte(post.Body))),
+				DatePublished: post.DatePosted.UTC().Format(time.RFC3339),
+				Author:        author,
+			})
+		}
+		feed := jsonFeed{
+			Version:     "https://jsonfeed.org/version/1.1",
+			Title:       siteMetaData.Title,
+			HomePageURL: fmt.Sprintf("https://%s/", cfg.Domain),
+			FeedURL:     fmt.Sprintf("https://%s/feed.json", cfg.Domain),
+			Items:       items,
+		}
+
+		res.Header().Set("Content-Type", "application/feed+json; charset=UTF-8")
+		json.NewEncoder(res).Encode(feed)
+	}
+}
+
+// sitemapHandler answers GET /sitemap.xml listing every post's canonical URL.
+func sitemapHandler(ps store.PostStore, cfg config) http.HandlerFunc {
+	return func(res http.ResponseWriter, r *http.Request) {
+		posts, err := ps.List()
+		if err != nil {
+			res.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if writeFeedCacheHeaders(res, r, latestPostDate(posts)) {
+			res.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		urls := make([]sitemapURL, 0, len(posts)+1)
+		urls = append(urls, sitemapURL{Loc: fmt.Sprintf("https://%s/", cfg.Domain)})
+		for _, post := range sortedPosts(posts) {
+			urls = append(urls, sitemapURL{
+				Loc:     postURL(cfg, post.Slug),
+				LastMod: post.DatePosted.UTC().Format("2006-01-02"),
+			})
+		}
+		sitemap := sitemapURLSet{
+			Xmlns: "http://www.sitemaps.org/schemas/sitemap/0.9",
+			URLs:  urls,
+		}
+
+		res.Header().Set("Content-Type", "application/xml; charset=UTF-8")
+		res.Write([]byte(xml.Header))
+		xml.NewEncoder(res).Encode(sitemap)
+	}
+}
@@ -18,6 +18,9 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/gosimple/slug"
 	"github.com/microcosm-cc/bluemonday"
+
+	"github.com/codeworkshop-dev/go-tiny-blog/auth"
+	"github.com/codeworkshop-dev/go-tiny-blog/store"
 )
 
 // SiteMetaData is general information about the Site
@@ -26,18 +29,6 @@ type SiteMetaData struct {
 	Description string
 }
 
-// Post is the data required to render the HTML template for the post page.
-type Post struct {
-	Author     string    `json:"author,omitempty"`
-	Body       string    `json:"body,omitempty"`
-	DatePosted time.Time `json:"datePosted,omitempty"`
-	Title      string    `json:"title,omitempty"`
-	Slug       string    `json:"slug,omitempty"`
-}
-
-// PostMap is a map of posts with the slug as the key.
-type PostMap map[string]Post
-
 // Test Data
 
 var siteMetaData = SiteMetaData{
@@ -49,27 +40,47 @@ var siteMetaData = SiteMetaData{
 // It is made up of the site meta data, and a map of all of the posts.
 type HomePageData struct {
 	SiteMetaData SiteMetaData
-	Posts        PostMap
+	Posts        store.PostMap
+	CSRFToken    string
 }
 
 // PostPageData is the data required to render the HTML template for the post page.
 // It is made up of the site meta data, and a Post struct.
 type PostPageData struct {
-	SiteMetaData SiteMetaData
-	Post         Post
-	HTML         template.HTML
+	SiteMetaData   SiteMetaData
+	Post           store.Post
+	HTML           template.HTML
+	CSRFToken      string
+	SeriesPrevious *store.Post
+	SeriesNext     *store.Post
 }
 
 func main() {
 
-	db, err := setupDB()
+	cfg := loadConfig()
+
+	db, err := setupDB(cfg)
 	defer db.Close()
 
 	if err != nil {
 		log.Println(err)
 	}
 
-	r := newRouter(db)
+	ps, closePS, err := newPostStore(cfg, db)
+	if err != nil {
+		log.Fatalf("could not set up post store: %v", err)
+	}
+	defer closePS()
+
+	hashKey, blockKey, err := auth.LoadOrCreateSessionKeys(db)
+	if err != nil {
+		log.Fatalf("could not set up session keys: %v", err)
+	}
+	sm := auth.NewSessionManager(hashKey, blockKey)
+
+	startDeliveryWorker(db, cfg)
+
+	r := newRouter(ps, db, cfg, sm)
 	// Create http server and run inside go routine for graceful shutdown.
 	srv := &http.Server{
 		Handler:      r,
@@ -102,10 +113,42 @@ func main() {
 	os.Exit(0)
 }
 
+// newPostStore selects and constructs the PostStore backend named by
+// cfg.StorageBackend, returning a cleanup function for any resources it
+// opened. The BoltDB backend reuses the app's own db handle rather than
+// opening tinyblog.db a second time.
+func newPostStore(cfg config, db *bolt.DB) (store.PostStore, func(), error) {
+	switch cfg.StorageBackend {
+	case "", "bolt":
+		ps, err := store.NewBoltStore(db)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := ps.RebuildSearchIndexIfNeeded(); err != nil {
+			log.Println(err)
+		}
+		return ps, func() {}, nil
+	case "file":
+		ps, err := store.NewFileStore(cfg.ContentDir)
+		if err != nil {
+			return nil, nil, err
+		}
+		return ps, func() {}, nil
+	case "sqlite":
+		ps, err := store.NewSQLiteStore(cfg.SQLitePath)
+		if err != nil {
+			return nil, nil, err
+		}
+		return ps, func() { ps.Close() }, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown storage backend %q", cfg.StorageBackend)
+	}
+}
+
 // homeHandler returns the list of blog posts rendered in an HTML template.
-func homeHandler(db *bolt.DB, t *template.Template) http.HandlerFunc {
+func homeHandler(ps store.PostStore, t *template.Template) http.HandlerFunc {
 	fn := func(res http.ResponseWriter, r *http.Request) {
-		postData, err := listPosts(db)
+		postData, err := ps.List()
 		if err != nil {
 			res.Header().Set("Content-Type", "text/plain; charset=UTF-8")
 			res.WriteHeader(http.StatusInternalServerError)
@@ -122,24 +165,31 @@ func homeHandler(db *bolt.DB, t *template.Template) http.HandlerFunc {
 }
 
 // createPostPageHandler serves the UI for creating a post. It is a form that submits to the create post REST endpoint.
-func createPostPageHandler(db *bolt.DB, t *template.Template) http.HandlerFunc {
+// It issues a fresh CSRF token and embeds it in the page, since the create
+// post REST endpoint requires one.
+func createPostPageHandler(ps store.PostStore, t *template.Template, sm *auth.SessionManager) http.HandlerFunc {
 	fn := func(res http.ResponseWriter, r *http.Request) {
 		log.Println("Requested the create post page.")
+		token, err := auth.NewCSRFToken()
+		if err != nil || sm.SetCSRFCookie(res, token) != nil {
+			res.WriteHeader(http.StatusInternalServerError)
+			return
+		}
 		res.Header().Set("Content-Type", "text/html; charset=UTF-8")
 		res.WriteHeader(http.StatusOK)
-		t.Execute(res, HomePageData{SiteMetaData: siteMetaData})
+		t.Execute(res, HomePageData{SiteMetaData: siteMetaData, CSRFToken: token})
 	}
 
 	return fn
 }
 
 // postHandler looks up a specific blog post and returns it as an HTML template.
-func getPostHandler(db *bolt.DB, t *template.Template) http.HandlerFunc {
+func getPostHandler(ps store.PostStore, t *template.Template) http.HandlerFunc {
 
 	fn := func(res http.ResponseWriter, r *http.Request) {
 		// Get the URL param named slug from the response.
 		slug := mux.Vars(r)["slug"]
-		post, err := getPost(db, slug)
+		post, err := ps.Get(slug)
 		if err != nil {
 			res.Header().Set("Content-Type", "text/plain; charset=UTF-8")
 			res.WriteHeader(http.StatusNotFound)
@@ -147,31 +197,121 @@ func getPostHandler(db *bolt.DB, t *template.Template) http.HandlerFunc {
 			return
 		}
 		log.Printf("Requested: %s by %s \n", post.Title, post.Author)
-		unsafePostHTML := markdown.ToHTML([]byte(post.Body), nil, nil)
-		postHTML := bluemonday.UGCPolicy().SanitizeBytes(unsafePostHTML)
+		previous, next := seriesNeighbors(ps, *post)
 		res.Header().Set("Content-Type", "text/html; charset=UTF-8")
 		res.WriteHeader(http.StatusOK)
-		t.Execute(res, PostPageData{SiteMetaData: siteMetaData, Post: *post, HTML: template.HTML(postHTML)})
+		t.Execute(res, PostPageData{
+			SiteMetaData:   siteMetaData,
+			Post:           *post,
+			HTML:           renderPostHTML([]byte(post.Body)),
+			SeriesPrevious: previous,
+			SeriesNext:     next,
+		})
 	}
 	return fn
 }
 
+// seriesNeighbors returns the posts immediately before and after post within
+// its series (oldest-first order), or nil, nil if post isn't in a series or
+// the backend can't look series up.
+func seriesNeighbors(ps store.PostStore, post store.Post) (previous, next *store.Post) {
+	if post.Series == "" {
+		return nil, nil
+	}
+	tagIndex, ok := ps.(store.TagIndex)
+	if !ok {
+		return nil, nil
+	}
+	series, err := tagIndex.PostsBySeries(post.Series)
+	if err != nil {
+		return nil, nil
+	}
+	for i, p := range series {
+		if p.Slug != post.Slug {
+			continue
+		}
+		if i > 0 {
+			previous = &series[i-1]
+		}
+		if i < len(series)-1 {
+			next = &series[i+1]
+		}
+		return previous, next
+	}
+	return nil, nil
+}
+
+// TagPageData is the data required to render the HTML template listing
+// posts that share a tag or belong to a series.
+type TagPageData struct {
+	SiteMetaData SiteMetaData
+	Heading      string
+	Posts        []store.Post
+}
+
+// tagHandler answers GET /tag/{tag} with every post carrying that tag, for
+// backends that implement store.TagIndex.
+func tagHandler(ps store.PostStore, t *template.Template) http.HandlerFunc {
+	return func(res http.ResponseWriter, r *http.Request) {
+		tag := mux.Vars(r)["tag"]
+		var posts []store.Post
+		if tagIndex, ok := ps.(store.TagIndex); ok {
+			var err error
+			posts, err = tagIndex.PostsByTag(tag)
+			if err != nil {
+				res.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+		}
+		res.Header().Set("Content-Type", "text/html; charset=UTF-8")
+		res.WriteHeader(http.StatusOK)
+		t.Execute(res, TagPageData{SiteMetaData: siteMetaData, Heading: "Tagged: " + tag, Posts: posts})
+	}
+}
+
+// seriesHandler answers GET /series/{name} with every post in that series,
+// oldest first, for backends that implement store.TagIndex.
+func seriesHandler(ps store.PostStore, t *template.Template) http.HandlerFunc {
+	return func(res http.ResponseWriter, r *http.Request) {
+		name := mux.Vars(r)["name"]
+		var posts []store.Post
+		if tagIndex, ok := ps.(store.TagIndex); ok {
+			var err error
+			posts, err = tagIndex.PostsBySeries(name)
+			if err != nil {
+				res.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+		}
+		res.Header().Set("Content-Type", "text/html; charset=UTF-8")
+		res.WriteHeader(http.StatusOK)
+		t.Execute(res, TagPageData{SiteMetaData: siteMetaData, Heading: "Series: " + name, Posts: posts})
+	}
+}
+
 // editPostPageHandler serves the UI for creating a post. It is a form that submits to the create post REST endpoint.
-func editPostPageHandler(db *bolt.DB, t *template.Template) http.HandlerFunc {
+// It issues a fresh CSRF token and embeds it in the page, since the modify
+// post REST endpoint requires one.
+func editPostPageHandler(ps store.PostStore, t *template.Template, sm *auth.SessionManager) http.HandlerFunc {
 	fn := func(res http.ResponseWriter, r *http.Request) {
 		// Get the URL param named slug from the response.
 		slug := mux.Vars(r)["slug"]
-		post, err := getPost(db, slug)
+		post, err := ps.Get(slug)
 		if err != nil {
 			res.Header().Set("Content-Type", "text/plain; charset=UTF-8")
 			res.WriteHeader(http.StatusNotFound)
 			res.Write([]byte("404 Page Not Found"))
 			return
 		}
+		token, err := auth.NewCSRFToken()
+		if err != nil || sm.SetCSRFCookie(res, token) != nil {
+			res.WriteHeader(http.StatusInternalServerError)
+			return
+		}
 		log.Printf("Requested edit page for: %s by %s \n", post.Title, post.Author)
 		res.Header().Set("Content-Type", "text/html; charset=UTF-8")
 		res.WriteHeader(http.StatusOK)
-		t.Execute(res, PostPageData{SiteMetaData: siteMetaData, Post: *post})
+		t.Execute(res, PostPageData{SiteMetaData: siteMetaData, Post: *post, CSRFToken: token})
 	}
 
 	return fn
@@ -180,9 +320,9 @@ func editPostPageHandler(db *bolt.DB, t *template.Template) http.HandlerFunc {
 // createPostHandler handles posted JSON data representing a new post, and stores it in the database.
 // It creates a slug to use as a key using the title of the post.
 // This implies in the current state of affairs that titles must be unique or the keys will overwrite each other.
-func createPostHandler(db *bolt.DB) http.HandlerFunc {
+func createPostHandler(ps store.PostStore, db *bolt.DB, cfg config) http.HandlerFunc {
 	fn := func(res http.ResponseWriter, r *http.Request) {
-		var post Post
+		var post store.Post
 		res.Header().Set("Content-Type", "text/plain; charset=UTF-8")
 		// Reads in the body content from the post request safely limiting to max size.
 		body, err := ioutil.ReadAll(io.LimitReader(r.Body, 1048576))
@@ -211,11 +351,12 @@ func createPostHandler(db *bolt.DB) http.HandlerFunc {
 		autoSlug := fmt.Sprintf("%s-%s", slug.Make(post.DatePosted.Format(time.RFC3339)), slug.Make(post.Title))
 		post.Slug = autoSlug
 
-		if err = upsertPost(db, post, autoSlug); err != nil {
+		if err = ps.Upsert(post, autoSlug); err != nil {
 			res.WriteHeader(http.StatusInternalServerError)
 			res.Write([]byte("Error writing to DB."))
 			return
 		}
+		enqueueDelivery(db, cfg, createActivityForPost(cfg, autoSlug, post))
 
 		res.Header().Set("Content-Type", "application/json; charset=UTF-8")
 		res.WriteHeader(http.StatusCreated)
@@ -231,9 +372,9 @@ func createPostHandler(db *bolt.DB) http.HandlerFunc {
 // It writes the new post object to the URL slug value unlike the createPostHandler
 // which generates a new slug using the post date and time. Notice this means you can not change the URI.
 // This is left as homework for the reader.
-func modifyPostHandler(db *bolt.DB) http.HandlerFunc {
+func modifyPostHandler(ps store.PostStore, db *bolt.DB, cfg config) http.HandlerFunc {
 	fn := func(res http.ResponseWriter, r *http.Request) {
-		var post Post
+		var post store.Post
 		slug := mux.Vars(r)["slug"]
 		res.Header().Set("Content-Type", "application/json; charset=UTF-8")
 		body, err := ioutil.ReadAll(io.LimitReader(r.Body, 1048576))
@@ -250,14 +391,25 @@ func modifyPostHandler(db *bolt.DB) http.HandlerFunc {
 			}
 		}
 		post.Slug = slug
-		post.DatePosted = time.Now()
-		// Call the upsertPost function passing in the database, a post struct, and the slug.
-		// If there is an error writing to the database write an error to the response and return.
-		if err = upsertPost(db, post, slug); err != nil {
+		// Preserve the original DatePosted rather than re-stamping it to now:
+		// taxonomy's SERIES index orders posts by DatePosted, so clobbering it
+		// on every edit would reshuffle SeriesPrevious/SeriesNext for the whole
+		// series each time one of its posts was touched.
+		if existing, err := ps.Get(slug); err == nil {
+			post.DatePosted = existing.DatePosted
+		} else {
+			post.DatePosted = time.Now()
+		}
+		// Call the Upsert method passing in a post struct and the slug.
+		// If there is an error writing to the store write an error to the response and return.
+		if err = ps.Upsert(post, slug); err != nil {
 			res.WriteHeader(http.StatusInternalServerError)
 			res.Write([]byte("Error writing to DB."))
 			return
 		}
+		updateActivity := createActivityForPost(cfg, slug, post)
+		updateActivity.Type = "Update"
+		enqueueDelivery(db, cfg, updateActivity)
 		res.WriteHeader(http.StatusCreated)
 		if err := json.NewEncoder(res).Encode(post); err != nil {
 			panic(err)
@@ -267,13 +419,20 @@ func modifyPostHandler(db *bolt.DB) http.HandlerFunc {
 }
 
 // DeletePostHandler deletes the post with the key matching the slug in the URL.
-func deletePostHandler(db *bolt.DB) http.HandlerFunc {
+func deletePostHandler(ps store.PostStore, db *bolt.DB, cfg config) http.HandlerFunc {
 	fn := func(res http.ResponseWriter, r *http.Request) {
 		res.Header().Set("Content-Type", "application/json; charset=UTF-8")
 		slug := mux.Vars(r)["slug"]
-		if err := deletePost(db, slug); err != nil {
+		if err := ps.Delete(slug); err != nil {
 			panic(err)
 		}
+		deleteActivity := activity{
+			Context: activityPubContext,
+			Type:    "Delete",
+			Actor:   actorURL(cfg),
+			Object:  fmt.Sprintf("https://%s/%s", cfg.Domain, slug),
+		}
+		enqueueDelivery(db, cfg, deleteActivity)
 		res.WriteHeader(http.StatusOK)
 		if err := json.NewEncoder(res).Encode(struct {
 			Deleted bool
@@ -286,88 +445,26 @@ func deletePostHandler(db *bolt.DB) http.HandlerFunc {
 	return fn
 }
 
-// DATA STORE FUNCTIONS
-
-// upsertPost writes a post to the boltDB KV store using the slug as a key, and a serialized post struct as the value.
-// If the slug already exists the existing post will be overwritten.
-func upsertPost(db *bolt.DB, post Post, slug string) error {
-
-	// Marshal post struct into bytes which can be written to Bolt.
-	buf, err := json.Marshal(post)
-	if err != nil {
-		return err
-	}
-
-	err = db.Update(func(tx *bolt.Tx) error {
-		err := tx.Bucket([]byte("BLOG")).Bucket([]byte("POSTS")).Put([]byte(slug), []byte(buf))
-		if err != nil {
-			return fmt.Errorf("could not insert content: %v", err)
-		}
-		return nil
-	})
-	return err
-}
-
-// listPosts returns a map of posts indexed by the slug.
-// TODO: We could we add pagination to this!
-func listPosts(db *bolt.DB) (PostMap, error) {
-	results := PostMap{}
-	err := db.View(func(tx *bolt.Tx) error {
-		// Assume bucket exists and has keys
-		b := tx.Bucket([]byte("BLOG")).Bucket([]byte("POSTS"))
-
-		c := b.Cursor()
-
-		for k, v := c.First(); k != nil; k, v = c.Next() {
-			post := Post{}
-			if err := json.Unmarshal(v, &post); err != nil {
-				panic(err)
-			}
-			results[string(k)] = post
-		}
-
-		return nil
-	})
-	if err != nil {
-		return nil, err
-	}
-	return results, nil
-}
-
-// getPost gets a specific post from the database by the slug.
-func getPost(db *bolt.DB, slug string) (*Post, error) {
-	result := Post{}
-	err := db.View(func(tx *bolt.Tx) error {
-		// Assume bucket exists and has keys
-		b := tx.Bucket([]byte("BLOG")).Bucket([]byte("POSTS"))
-		v := b.Get([]byte(slug))
-		if err := json.Unmarshal(v, &result); err != nil {
-			return err
-		}
-		return nil
-	})
-	if err != nil {
-		return nil, err
-	}
-	return &result, nil
-}
-
-// deletePost deletes a specific post by slug.
-func deletePost(db *bolt.DB, slug string) error {
-	err := db.Update(func(tx *bolt.Tx) error {
-		err := tx.Bucket([]byte("BLOG")).Bucket([]byte("POSTS")).Delete([]byte(slug))
-		if err != nil {
-			return fmt.Errorf("could not delete content: %v", err)
-		}
-		return nil
-	})
-	return err
+// renderPostHTML converts a post body from markdown to sanitized HTML safe for
+// direct inclusion in a template. It is the single place that pipeline lives so
+// every consumer (the post page, federated activities, ...) renders identically.
+func renderPostHTML(body []byte) template.HTML {
+	unsafeHTML := markdown.ToHTML(body, nil, nil)
+	safeHTML := bluemonday.UGCPolicy().SanitizeBytes(unsafeHTML)
+	return template.HTML(safeHTML)
 }
 
 // INITIALIZATION FUNCTIONS
 // setupDB sets up the database when the program start.
-//  First it connects to the database, then it creates the buckets required to run the app if they do not exist.
-func setupDB() (*bolt.DB, error) {
+//
+//	First it connects to the database, then it creates the buckets required to run the app if they do not exist.
+//	Posts themselves live behind the configured PostStore backend (see newPostStore); this db only holds the
+//	subsystems that are always BoltDB-backed regardless of that choice: federation keys/followers, the search
+//	index, and user credentials.
+//
+//	It also bootstraps the initial admin user from cfg.AdminUsername/AdminPassword, if set and no such user
+//	already exists.
+func setupDB(cfg config) (*bolt.DB, error) {
 	db, err := bolt.Open("tinyblog.db", 0600, nil)
 	if err != nil {
 		return nil, fmt.Errorf("could not open db, %v", err)
@@ -377,36 +474,80 @@ func setupDB() (*bolt.DB, error) {
 		if err != nil {
 			return fmt.Errorf("could not create root bucket: %v", err)
 		}
-		_, err = root.CreateBucketIfNotExists([]byte("POSTS"))
+		_, err = root.CreateBucketIfNotExists([]byte("KEYS"))
 		if err != nil {
-			return fmt.Errorf("could not create post bucket: %v", err)
+			return fmt.Errorf("could not create keys bucket: %v", err)
+		}
+		_, err = root.CreateBucketIfNotExists([]byte("FOLLOWERS"))
+		if err != nil {
+			return fmt.Errorf("could not create followers bucket: %v", err)
+		}
+		if err := auth.CreateUsersBucket(tx); err != nil {
+			return fmt.Errorf("could not create users bucket: %v", err)
 		}
 		return nil
 	})
 	if err != nil {
 		return nil, fmt.Errorf("could not set up buckets, %v", err)
 	}
+	if err := auth.EnsureAdmin(db, cfg.AdminUsername, cfg.AdminPassword); err != nil {
+		return nil, fmt.Errorf("could not bootstrap admin user: %v", err)
+	}
 	fmt.Println("DB Setup Done")
 	return db, nil
 }
 
 // newRouter configures and sets up the gorilla mux router paths and connects the route to the handler function.
-func newRouter(db *bolt.DB) *mux.Router {
+func newRouter(ps store.PostStore, db *bolt.DB, cfg config, sm *auth.SessionManager) *mux.Router {
 
 	// Load and parse the html templates to be used.
-	homeTemplate := template.Must(template.ParseFiles("templates/home.html"))
+	// home.html needs canonicalURL to render a <link rel="alternate"> tag per
+	// post, so it's parsed with a FuncMap instead of the plain ParseFiles the
+	// other templates use.
+	homeTemplate := template.Must(template.New("home.html").Funcs(template.FuncMap{
+		"canonicalURL": func(slug string) string { return postURL(cfg, slug) },
+	}).ParseFiles("templates/home.html"))
 	postTemplate := template.Must(template.ParseFiles("templates/post.html"))
 	editTemplate := template.Must(template.ParseFiles("templates/edit-post.html"))
 	createTemplate := template.Must(template.ParseFiles("templates/create-post.html"))
+	loginTemplate := template.Must(template.ParseFiles("templates/login.html"))
 	r := mux.NewRouter()
 	r.StrictSlash(true)
-	r.HandleFunc("/", homeHandler(db, homeTemplate)).Methods("GET")
-	r.HandleFunc("/", createPostHandler(db)).Methods("POST")
-	r.HandleFunc("/create", createPostPageHandler(db, createTemplate)).Methods("GET")
-	r.HandleFunc("/{slug}", getPostHandler(db, postTemplate)).Methods("GET")
-	r.HandleFunc("/{slug}", modifyPostHandler(db)).Methods("POST")
-	r.HandleFunc("/{slug}", deletePostHandler(db)).Methods("DELETE")
-
-	r.HandleFunc("/{slug}/edit", editPostPageHandler(db, editTemplate)).Methods("GET")
+
+	// gorilla/mux matches routes in registration order, not by
+	// literal-vs-wildcard specificity, so every literal single-path-segment
+	// route below must be registered before the catch-all "/{slug}" routes
+	// further down, or it'll be silently swallowed by getPostHandler et al.
+	r.HandleFunc("/", homeHandler(ps, homeTemplate)).Methods("GET")
+	r.Handle("/", sm.RequireAuth(sm.RequireCSRF(createPostHandler(ps, db, cfg)))).Methods("POST")
+	r.HandleFunc("/create", createPostPageHandler(ps, createTemplate, sm)).Methods("GET")
+	r.Handle("/editor/preview", sm.RequireAuth(editorPreviewHandler(cfg))).Methods("GET")
+
+	r.HandleFunc("/login", loginPageHandler(loginTemplate)).Methods("GET")
+	r.HandleFunc("/login", loginHandler(db, sm, loginTemplate)).Methods("POST")
+	r.HandleFunc("/logout", logoutHandler(sm)).Methods("POST")
+
+	searchTemplate := template.Must(template.ParseFiles("templates/search.html"))
+	r.HandleFunc("/search", searchHandler(ps, searchTemplate)).Methods("GET")
+
+	tagTemplate := template.Must(template.ParseFiles("templates/tag.html"))
+	r.HandleFunc("/tag/{tag}", tagHandler(ps, tagTemplate)).Methods("GET")
+	r.HandleFunc("/series/{name}", seriesHandler(ps, tagTemplate)).Methods("GET")
+
+	r.HandleFunc("/feed.rss", feedRSSHandler(ps, cfg)).Methods("GET")
+	r.HandleFunc("/feed.atom", feedAtomHandler(ps, cfg)).Methods("GET")
+	r.HandleFunc("/feed.json", feedJSONHandler(ps, cfg)).Methods("GET")
+	r.HandleFunc("/sitemap.xml", sitemapHandler(ps, cfg)).Methods("GET")
+
+	registerActivityPubRoutes(r, db, ps, cfg)
+
+	// Catch-all single-segment post routes: must stay last among
+	// single-segment routes, since "/{slug}" matches anything not already
+	// claimed above.
+	r.HandleFunc("/{slug}", getPostHandler(ps, postTemplate)).Methods("GET")
+	r.Handle("/{slug}", sm.RequireAuth(sm.RequireCSRF(modifyPostHandler(ps, db, cfg)))).Methods("POST")
+	r.Handle("/{slug}", sm.RequireAuth(sm.RequireCSRF(deletePostHandler(ps, db, cfg)))).Methods("DELETE")
+	r.HandleFunc("/{slug}/edit", editPostPageHandler(ps, editTemplate, sm)).Methods("GET")
+
 	return r
 }
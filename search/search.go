@@ -0,0 +1,363 @@
+// Package search maintains a BM25-ranked inverted index over post titles and
+// bodies, stored transactionally alongside the posts themselves in the INDEX
+// bucket so the two never drift apart.
+package search
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/boltdb/bolt"
+)
+
+// BM25 tuning parameters, as commonly recommended for short-to-medium
+// documents.
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+var (
+	wordPattern     = regexp.MustCompile(`[\p{L}\p{N}]+`)
+	htmlTagPattern  = regexp.MustCompile(`<[^>]*>`)
+	markdownPattern = regexp.MustCompile("[`*_#>/\\[\\]()!~-]")
+)
+
+// stopwords are dropped during tokenization; this is a small common-English
+// list, not an exhaustive one.
+var stopwords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true,
+	"be": true, "but": true, "by": true, "for": true, "from": true,
+	"has": true, "have": true, "if": true, "in": true, "into": true,
+	"is": true, "it": true, "its": true, "of": true, "on": true, "or": true,
+	"that": true, "the": true, "their": true, "this": true, "to": true,
+	"was": true, "were": true, "will": true, "with": true,
+}
+
+// Document is a post's searchable content, keyed by slug.
+type Document struct {
+	Slug  string
+	Title string
+	Body  string
+}
+
+// Result is a single ranked search hit.
+type Result struct {
+	Slug  string
+	Score float64
+}
+
+// indexMeta tracks the corpus-wide statistics BM25 needs: how many documents
+// are indexed, and their total token length (used to derive the average
+// document length).
+type indexMeta struct {
+	TotalDocs   int
+	TotalLength int
+}
+
+// Tokenize lowercases s, strips HTML/markdown markup, splits on Unicode word
+// boundaries, drops stopwords, and stems the remaining terms.
+func Tokenize(s string) []string {
+	stripped := htmlTagPattern.ReplaceAllString(s, " ")
+	stripped = markdownPattern.ReplaceAllString(stripped, " ")
+	words := wordPattern.FindAllString(strings.ToLower(stripped), -1)
+
+	tokens := make([]string, 0, len(words))
+	for _, w := range words {
+		if stopwords[w] {
+			continue
+		}
+		tokens = append(tokens, stem(w))
+	}
+	return tokens
+}
+
+// stem applies a small set of suffix-stripping rules in the spirit of the
+// Porter stemmer (plurals, -ing, -ed) without implementing its full step
+// table; good enough to collapse "posts"/"posting"/"posted" onto "post".
+func stem(w string) string {
+	switch {
+	case strings.HasSuffix(w, "ies") && len(w) > 4:
+		return w[:len(w)-3] + "y"
+	case strings.HasSuffix(w, "ing") && len(w) > 5:
+		return strings.TrimSuffix(w, "ing")
+	case strings.HasSuffix(w, "ed") && len(w) > 4:
+		return strings.TrimSuffix(w, "ed")
+	case strings.HasSuffix(w, "es") && len(w) > 4:
+		return strings.TrimSuffix(w, "es")
+	case strings.HasSuffix(w, "s") && !strings.HasSuffix(w, "ss") && len(w) > 3:
+		return strings.TrimSuffix(w, "s")
+	}
+	return w
+}
+
+// IndexPost (re)indexes a single post's title and body inside tx, so the
+// index is updated in the same transaction as the post write it describes.
+// Calling it on a slug that is already indexed replaces its entry.
+func IndexPost(tx *bolt.Tx, slug, title, body string) error {
+	b, err := bucket(tx)
+	if err != nil {
+		return err
+	}
+	if err := removeFromBucket(b, slug); err != nil {
+		return err
+	}
+
+	tokens := Tokenize(title + " " + body)
+	tf := map[string]int{}
+	for _, t := range tokens {
+		tf[t]++
+	}
+
+	terms := make([]string, 0, len(tf))
+	for term, count := range tf {
+		terms = append(terms, term)
+		postings, err := loadPostings(b, term)
+		if err != nil {
+			return err
+		}
+		postings[slug] = count
+		if err := savePostings(b, term, postings); err != nil {
+			return err
+		}
+	}
+
+	termsJSON, err := json.Marshal(terms)
+	if err != nil {
+		return err
+	}
+	if err := b.Put(docTermsKey(slug), termsJSON); err != nil {
+		return fmt.Errorf("search: could not store doc terms: %v", err)
+	}
+	if err := b.Put(docLenKey(slug), []byte(strconv.Itoa(len(tokens)))); err != nil {
+		return fmt.Errorf("search: could not store doc length: %v", err)
+	}
+
+	m, err := loadMeta(b)
+	if err != nil {
+		return err
+	}
+	m.TotalDocs++
+	m.TotalLength += len(tokens)
+	return saveMeta(b, m)
+}
+
+// RemovePost removes a post's entries from the index inside tx.
+func RemovePost(tx *bolt.Tx, slug string) error {
+	b, err := bucket(tx)
+	if err != nil {
+		return err
+	}
+	return removeFromBucket(b, slug)
+}
+
+// removeFromBucket deletes slug's postings and adjusts the corpus metadata.
+// It is a no-op if slug was never indexed.
+func removeFromBucket(b *bolt.Bucket, slug string) error {
+	lenBytes := b.Get(docLenKey(slug))
+	if lenBytes == nil {
+		return nil
+	}
+	docLen, err := strconv.Atoi(string(lenBytes))
+	if err != nil {
+		return fmt.Errorf("search: corrupt doc length for %q: %v", slug, err)
+	}
+
+	var terms []string
+	if termsBytes := b.Get(docTermsKey(slug)); termsBytes != nil {
+		if err := json.Unmarshal(termsBytes, &terms); err != nil {
+			return fmt.Errorf("search: corrupt doc terms for %q: %v", slug, err)
+		}
+	}
+	for _, term := range terms {
+		postings, err := loadPostings(b, term)
+		if err != nil {
+			return err
+		}
+		delete(postings, slug)
+		if len(postings) == 0 {
+			if err := b.Delete(termKey(term)); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := savePostings(b, term, postings); err != nil {
+			return err
+		}
+	}
+	if err := b.Delete(docLenKey(slug)); err != nil {
+		return err
+	}
+	if err := b.Delete(docTermsKey(slug)); err != nil {
+		return err
+	}
+
+	m, err := loadMeta(b)
+	if err != nil {
+		return err
+	}
+	if m.TotalDocs > 0 {
+		m.TotalDocs--
+	}
+	m.TotalLength -= docLen
+	if m.TotalLength < 0 {
+		m.TotalLength = 0
+	}
+	return saveMeta(b, m)
+}
+
+// Search tokenizes query and ranks indexed posts by BM25 score, returning at
+// most limit results in descending order of relevance.
+func Search(db *bolt.DB, query string, limit int) ([]Result, error) {
+	var results []Result
+	err := db.View(func(tx *bolt.Tx) error {
+		b, err := bucket(tx)
+		if err != nil {
+			return err
+		}
+		m, err := loadMeta(b)
+		if err != nil {
+			return err
+		}
+		if m.TotalDocs == 0 {
+			return nil
+		}
+		avgdl := float64(m.TotalLength) / float64(m.TotalDocs)
+
+		seen := map[string]bool{}
+		scores := map[string]float64{}
+		for _, term := range Tokenize(query) {
+			if seen[term] {
+				continue
+			}
+			seen[term] = true
+
+			postings, err := loadPostings(b, term)
+			if err != nil {
+				return err
+			}
+			df := len(postings)
+			if df == 0 {
+				continue
+			}
+			idf := math.Log((float64(m.TotalDocs-df)+0.5)/(float64(df)+0.5) + 1)
+
+			for slug, tf := range postings {
+				docLen := avgdl
+				if lenBytes := b.Get(docLenKey(slug)); lenBytes != nil {
+					if n, err := strconv.Atoi(string(lenBytes)); err == nil {
+						docLen = float64(n)
+					}
+				}
+				numerator := float64(tf) * (bm25K1 + 1)
+				denominator := float64(tf) + bm25K1*(1-bm25B+bm25B*docLen/avgdl)
+				scores[slug] += idf * (numerator / denominator)
+			}
+		}
+		for slug, score := range scores {
+			results = append(results, Result{Slug: slug, Score: score})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+// IsEmpty reports whether the index has no documents in it, used at startup
+// to decide whether the index needs to be rebuilt from the POSTS bucket.
+func IsEmpty(db *bolt.DB) (bool, error) {
+	empty := true
+	err := db.View(func(tx *bolt.Tx) error {
+		b, err := bucket(tx)
+		if err != nil {
+			return err
+		}
+		m, err := loadMeta(b)
+		if err != nil {
+			return err
+		}
+		empty = m.TotalDocs == 0
+		return nil
+	})
+	return empty, err
+}
+
+// Rebuild clears the index and repopulates it from docs. Used on startup
+// when posts exist but the index bucket is empty.
+func Rebuild(db *bolt.DB, docs []Document) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		root := tx.Bucket([]byte("BLOG"))
+		if err := root.DeleteBucket([]byte("INDEX")); err != nil && err != bolt.ErrBucketNotFound {
+			return fmt.Errorf("search: could not clear index: %v", err)
+		}
+		if _, err := root.CreateBucket([]byte("INDEX")); err != nil {
+			return fmt.Errorf("search: could not recreate index bucket: %v", err)
+		}
+		for _, doc := range docs {
+			if err := IndexPost(tx, doc.Slug, doc.Title, doc.Body); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func bucket(tx *bolt.Tx) (*bolt.Bucket, error) {
+	b := tx.Bucket([]byte("BLOG")).Bucket([]byte("INDEX"))
+	if b == nil {
+		return nil, fmt.Errorf("search: INDEX bucket not found")
+	}
+	return b, nil
+}
+
+func loadPostings(b *bolt.Bucket, term string) (map[string]int, error) {
+	postings := map[string]int{}
+	if data := b.Get(termKey(term)); data != nil {
+		if err := json.Unmarshal(data, &postings); err != nil {
+			return nil, fmt.Errorf("search: corrupt postings for %q: %v", term, err)
+		}
+	}
+	return postings, nil
+}
+
+func savePostings(b *bolt.Bucket, term string, postings map[string]int) error {
+	data, err := json.Marshal(postings)
+	if err != nil {
+		return err
+	}
+	return b.Put(termKey(term), data)
+}
+
+func loadMeta(b *bolt.Bucket) (indexMeta, error) {
+	m := indexMeta{}
+	if data := b.Get([]byte("meta")); data != nil {
+		if err := json.Unmarshal(data, &m); err != nil {
+			return m, fmt.Errorf("search: corrupt index metadata: %v", err)
+		}
+	}
+	return m, nil
+}
+
+func saveMeta(b *bolt.Bucket, m indexMeta) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return b.Put([]byte("meta"), data)
+}
+
+func termKey(term string) []byte     { return []byte("term:" + term) }
+func docLenKey(slug string) []byte   { return []byte("doclen:" + slug) }
+func docTermsKey(slug string) []byte { return []byte("docterms:" + slug) }
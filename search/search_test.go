@@ -0,0 +1,134 @@
+package search
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/boltdb/bolt"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want []string
+	}{
+		{
+			name: "strips markup and stopwords, stems plurals",
+			in:   "<p>The **Posts** are *about* posting and posted things</p>",
+			want: []string{"post", "about", "post", "post", "thing"},
+		},
+		{
+			name: "empty input yields no tokens",
+			in:   "",
+			want: []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Tokenize(tt.in)
+			if len(got) == 0 {
+				got = []string{}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Tokenize(%q) = %v, want %v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStem(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"posts", "post"},
+		{"posting", "post"},
+		{"posted", "post"},
+		{"stories", "story"},
+		{"glass", "glass"}, // -ss is not treated as a plural suffix
+		{"it", "it"},       // too short to strip
+	}
+	for _, tt := range tests {
+		if got := stem(tt.in); got != tt.want {
+			t.Errorf("stem(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+// newTestIndex opens a fresh BoltDB with the buckets search needs, in a
+// temporary file that's cleaned up when the test ends.
+func newTestIndex(t *testing.T) *bolt.DB {
+	t.Helper()
+	db, err := bolt.Open(filepath.Join(t.TempDir(), "search.db"), 0600, nil)
+	if err != nil {
+		t.Fatalf("could not open test db: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		root, err := tx.CreateBucketIfNotExists([]byte("BLOG"))
+		if err != nil {
+			return err
+		}
+		_, err = root.CreateBucketIfNotExists([]byte("INDEX"))
+		return err
+	})
+	if err != nil {
+		t.Fatalf("could not create test buckets: %v", err)
+	}
+	return db
+}
+
+func TestSearchRanksMoreRelevantDocumentsFirst(t *testing.T) {
+	db := newTestIndex(t)
+
+	err := db.Update(func(tx *bolt.Tx) error {
+		if err := IndexPost(tx, "go-post", "Go", "Go is a programming language. Go is fast."); err != nil {
+			return err
+		}
+		return IndexPost(tx, "other-post", "Rust", "Rust is a different programming language.")
+	})
+	if err != nil {
+		t.Fatalf("could not index test posts: %v", err)
+	}
+
+	results, err := Search(db, "go programming", 10)
+	if err != nil {
+		t.Fatalf("Search returned error: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("Search returned %d results, want 2", len(results))
+	}
+	if results[0].Slug != "go-post" {
+		t.Errorf("top result = %q, want %q (more occurrences of the query term)", results[0].Slug, "go-post")
+	}
+	if results[0].Score <= results[1].Score {
+		t.Errorf("top result score %v should be greater than second result score %v", results[0].Score, results[1].Score)
+	}
+}
+
+func TestSearchRespectsLimit(t *testing.T) {
+	db := newTestIndex(t)
+
+	err := db.Update(func(tx *bolt.Tx) error {
+		for _, slug := range []string{"a", "b", "c"} {
+			if err := IndexPost(tx, slug, "post", "a shared search term"); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("could not index test posts: %v", err)
+	}
+
+	results, err := Search(db, "shared", 2)
+	if err != nil {
+		t.Fatalf("Search returned error: %v", err)
+	}
+	if len(results) != 2 {
+		t.Errorf("Search returned %d results, want 2 (limit)", len(results))
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"html/template"
+	"log"
+	"net/http"
+
+	"github.com/codeworkshop-dev/go-tiny-blog/store"
+)
+
+// SearchResultsPageData is the data required to render the HTML template for
+// the search results page.
+type SearchResultsPageData struct {
+	SiteMetaData SiteMetaData
+	Query        string
+	Results      []SearchResult
+}
+
+// SearchResult pairs a post with its relevance score for a given query.
+type SearchResult struct {
+	Post  store.Post
+	Score float64
+}
+
+// searchHandler answers GET /search?q=... with posts ranked by relevance to
+// the query. Not every PostStore backend can rank its own posts (see
+// store.Searcher); backends that can't return an empty result set rather
+// than erroring, since "no matches" and "unsupported" look the same to a
+// reader typing into a search box.
+func searchHandler(ps store.PostStore, t *template.Template) http.HandlerFunc {
+	fn := func(res http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query().Get("q")
+
+		var results []SearchResult
+		if searcher, ok := ps.(store.Searcher); ok {
+			hits, err := searcher.Search(query, 20)
+			if err != nil {
+				res.Header().Set("Content-Type", "text/plain; charset=UTF-8")
+				res.WriteHeader(http.StatusInternalServerError)
+				res.Write([]byte("Could not search posts."))
+				return
+			}
+			results = make([]SearchResult, 0, len(hits))
+			for _, hit := range hits {
+				post, err := ps.Get(hit.Slug)
+				if err != nil {
+					continue
+				}
+				results = append(results, SearchResult{Post: *post, Score: hit.Score})
+			}
+		}
+
+		log.Printf("Searched for %q, found %d results.\n", query, len(results))
+		res.Header().Set("Content-Type", "text/html; charset=UTF-8")
+		res.WriteHeader(http.StatusOK)
+		t.Execute(res, SearchResultsPageData{SiteMetaData: siteMetaData, Query: query, Results: results})
+	}
+	return fn
+}
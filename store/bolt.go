@@ -0,0 +1,201 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/boltdb/bolt"
+
+	"github.com/codeworkshop-dev/go-tiny-blog/search"
+	"github.com/codeworkshop-dev/go-tiny-blog/taxonomy"
+)
+
+// BoltStore is the original PostStore backend: posts are JSON blobs in the
+// BLOG/POSTS bucket of a BoltDB file, keyed by slug. It also keeps the
+// search package's full-text index transactionally in sync with the POSTS
+// bucket, so it is the only backend that satisfies Searcher.
+type BoltStore struct {
+	db *bolt.DB
+}
+
+// NewBoltStore wraps an already-open BoltDB handle, creating the buckets it
+// needs if they don't already exist. The caller owns db's lifecycle.
+func NewBoltStore(db *bolt.DB) (*BoltStore, error) {
+	err := db.Update(func(tx *bolt.Tx) error {
+		root, err := tx.CreateBucketIfNotExists([]byte("BLOG"))
+		if err != nil {
+			return fmt.Errorf("could not create root bucket: %v", err)
+		}
+		if _, err := root.CreateBucketIfNotExists([]byte("POSTS")); err != nil {
+			return fmt.Errorf("could not create post bucket: %v", err)
+		}
+		if _, err := root.CreateBucketIfNotExists([]byte("INDEX")); err != nil {
+			return fmt.Errorf("could not create index bucket: %v", err)
+		}
+		return taxonomy.CreateBuckets(tx)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &BoltStore{db: db}, nil
+}
+
+// Upsert writes a post to the POSTS bucket using the slug as a key, and
+// updates the full-text index and the tag/series indexes in the same
+// transaction so none of them ever drift from what's stored.
+func (s *BoltStore) Upsert(post Post, slug string) error {
+	buf, err := json.Marshal(post)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		postsBucket := tx.Bucket([]byte("BLOG")).Bucket([]byte("POSTS"))
+
+		var old Post
+		if existing := postsBucket.Get([]byte(slug)); existing != nil {
+			if err := json.Unmarshal(existing, &old); err != nil {
+				return fmt.Errorf("could not read existing post: %v", err)
+			}
+		}
+
+		if err := postsBucket.Put([]byte(slug), buf); err != nil {
+			return fmt.Errorf("could not insert content: %v", err)
+		}
+		if err := search.IndexPost(tx, slug, post.Title, post.Body); err != nil {
+			return err
+		}
+		return taxonomy.ReindexPost(tx, slug,
+			taxonomy.Entry{Tags: old.Tags, Series: old.Series, DatePosted: old.DatePosted},
+			taxonomy.Entry{Tags: post.Tags, Series: post.Series, DatePosted: post.DatePosted},
+		)
+	})
+}
+
+// Get returns the post stored under slug.
+func (s *BoltStore) Get(slug string) (*Post, error) {
+	result := Post{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte("BLOG")).Bucket([]byte("POSTS"))
+		v := b.Get([]byte(slug))
+		return json.Unmarshal(v, &result)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// List returns every post, keyed by slug.
+func (s *BoltStore) List() (PostMap, error) {
+	results := PostMap{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte("BLOG")).Bucket([]byte("POSTS"))
+		c := b.Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			post := Post{}
+			if err := json.Unmarshal(v, &post); err != nil {
+				return err
+			}
+			results[string(k)] = post
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// Delete removes the post stored under slug, along with its entries in the
+// full-text index and the tag/series indexes.
+func (s *BoltStore) Delete(slug string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		postsBucket := tx.Bucket([]byte("BLOG")).Bucket([]byte("POSTS"))
+
+		var old Post
+		if existing := postsBucket.Get([]byte(slug)); existing != nil {
+			if err := json.Unmarshal(existing, &old); err != nil {
+				return fmt.Errorf("could not read existing post: %v", err)
+			}
+		}
+
+		if err := postsBucket.Delete([]byte(slug)); err != nil {
+			return fmt.Errorf("could not delete content: %v", err)
+		}
+		if err := search.RemovePost(tx, slug); err != nil {
+			return err
+		}
+		return taxonomy.RemovePost(tx, slug, taxonomy.Entry{Tags: old.Tags, Series: old.Series, DatePosted: old.DatePosted})
+	})
+}
+
+// PostsByTag returns every post tagged with tag, delegating to the taxonomy
+// package's TAGS index.
+func (s *BoltStore) PostsByTag(tag string) ([]Post, error) {
+	slugs, err := taxonomy.PostsByTag(s.db, tag)
+	if err != nil {
+		return nil, err
+	}
+	return s.postsBySlugs(slugs)
+}
+
+// PostsBySeries returns every post in series, oldest first, delegating to
+// the taxonomy package's SERIES index.
+func (s *BoltStore) PostsBySeries(series string) ([]Post, error) {
+	slugs, err := taxonomy.PostsBySeries(s.db, series)
+	if err != nil {
+		return nil, err
+	}
+	return s.postsBySlugs(slugs)
+}
+
+func (s *BoltStore) postsBySlugs(slugs []string) ([]Post, error) {
+	posts := make([]Post, 0, len(slugs))
+	for _, slug := range slugs {
+		post, err := s.Get(slug)
+		if err != nil {
+			return nil, err
+		}
+		posts = append(posts, *post)
+	}
+	return posts, nil
+}
+
+// Search ranks this store's posts by BM25 relevance to query, delegating to
+// the search package's index.
+func (s *BoltStore) Search(query string, limit int) ([]SearchHit, error) {
+	results, err := search.Search(s.db, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	hits := make([]SearchHit, len(results))
+	for i, r := range results {
+		hits[i] = SearchHit{Slug: r.Slug, Score: r.Score}
+	}
+	return hits, nil
+}
+
+// RebuildSearchIndexIfNeeded repopulates the full-text index from the POSTS
+// bucket if posts exist but the index hasn't been built yet, e.g. when
+// upgrading a database created before search existed.
+func (s *BoltStore) RebuildSearchIndexIfNeeded() error {
+	empty, err := search.IsEmpty(s.db)
+	if err != nil {
+		return err
+	}
+	if !empty {
+		return nil
+	}
+	posts, err := s.List()
+	if err != nil {
+		return err
+	}
+	if len(posts) == 0 {
+		return nil
+	}
+	docs := make([]search.Document, 0, len(posts))
+	for slug, post := range posts {
+		docs = append(docs, search.Document{Slug: slug, Title: post.Title, Body: post.Body})
+	}
+	return search.Rebuild(s.db, docs)
+}
@@ -0,0 +1,169 @@
+package store
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// frontMatterDelimiter separates a post's YAML front matter from its
+// markdown body, mirroring how static-site-style Go blogs lay out content.
+const frontMatterDelimiter = "---\n"
+
+// FileStore is a PostStore backed by `.md` files with YAML front matter, one
+// file per post, named by slug.
+type FileStore struct {
+	dir string
+}
+
+// NewFileStore returns a FileStore rooted at dir, creating the directory if
+// it does not already exist.
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("could not create content directory: %v", err)
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+// path returns the on-disk location of slug's file, after rejecting slugs
+// that would let the caller escape dir (e.g. via "..", "/", or "\").
+func (s *FileStore) path(slug string) (string, error) {
+	if strings.ContainsAny(slug, `/\`) || strings.Contains(slug, "..") {
+		return "", fmt.Errorf("invalid slug %q", slug)
+	}
+	return filepath.Join(s.dir, slug+".md"), nil
+}
+
+// Upsert writes post's front matter and body to <slug>.md, overwriting any
+// existing file.
+func (s *FileStore) Upsert(post Post, slug string) error {
+	path, err := s.path(slug)
+	if err != nil {
+		return err
+	}
+	front, err := yaml.Marshal(post)
+	if err != nil {
+		return fmt.Errorf("could not marshal front matter: %v", err)
+	}
+	content := frontMatterDelimiter + string(front) + frontMatterDelimiter + post.Body
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		return fmt.Errorf("could not write post file: %v", err)
+	}
+	return nil
+}
+
+// Get reads and parses <slug>.md.
+func (s *FileStore) Get(slug string) (*Post, error) {
+	path, err := s.path(slug)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	post, err := parsePostFile(data)
+	if err != nil {
+		return nil, err
+	}
+	post.Slug = slug
+	return post, nil
+}
+
+// List parses every `.md` file in the content directory.
+func (s *FileStore) List() (PostMap, error) {
+	matches, err := filepath.Glob(filepath.Join(s.dir, "*.md"))
+	if err != nil {
+		return nil, err
+	}
+	results := PostMap{}
+	for _, path := range matches {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		post, err := parsePostFile(data)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse %s: %v", path, err)
+		}
+		slug := strings.TrimSuffix(filepath.Base(path), ".md")
+		post.Slug = slug
+		results[slug] = *post
+	}
+	return results, nil
+}
+
+// Delete removes <slug>.md. Deleting a slug that was never written is a
+// no-op, matching BoltStore and SQLiteStore, where deleting a missing key is
+// harmless.
+func (s *FileStore) Delete(slug string) error {
+	path, err := s.path(slug)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// PostsByTag returns every post tagged with tag. There's no secondary index
+// for the file backend, so this scans the content directory.
+func (s *FileStore) PostsByTag(tag string) ([]Post, error) {
+	all, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	var matches []Post
+	for _, post := range all {
+		for _, t := range post.Tags {
+			if t == tag {
+				matches = append(matches, post)
+				break
+			}
+		}
+	}
+	return matches, nil
+}
+
+// PostsBySeries returns every post in series, oldest first.
+func (s *FileStore) PostsBySeries(series string) ([]Post, error) {
+	all, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	var matches []Post
+	for _, post := range all {
+		if post.Series == series {
+			matches = append(matches, post)
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].DatePosted.Before(matches[j].DatePosted) })
+	return matches, nil
+}
+
+// parsePostFile splits a file's YAML front matter from its markdown body and
+// decodes the front matter into a Post.
+func parsePostFile(data []byte) (*Post, error) {
+	content := string(data)
+	if !strings.HasPrefix(content, frontMatterDelimiter) {
+		return nil, fmt.Errorf("missing front matter delimiter")
+	}
+	rest := content[len(frontMatterDelimiter):]
+	end := strings.Index(rest, frontMatterDelimiter)
+	if end == -1 {
+		return nil, fmt.Errorf("unterminated front matter")
+	}
+
+	post := &Post{}
+	if err := yaml.Unmarshal([]byte(rest[:end]), post); err != nil {
+		return nil, fmt.Errorf("could not parse front matter: %v", err)
+	}
+	post.Body = rest[end+len(frontMatterDelimiter):]
+	return post, nil
+}
@@ -0,0 +1,238 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	_ "modernc.org/sqlite"
+)
+
+// SQLiteStore is a PostStore backed by SQLite, with an FTS5 virtual table
+// kept in sync via triggers so Search doesn't need any bookkeeping of its
+// own.
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+// NewSQLiteStore opens (creating if necessary) a SQLite database at path and
+// ensures its schema exists.
+func NewSQLiteStore(path string) (*SQLiteStore, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open sqlite database: %v", err)
+	}
+	s := &SQLiteStore{db: db}
+	if err := s.migrate(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *SQLiteStore) migrate() error {
+	_, err := s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS posts (
+			slug        TEXT PRIMARY KEY,
+			title       TEXT NOT NULL DEFAULT '',
+			author      TEXT NOT NULL DEFAULT '',
+			body        TEXT NOT NULL DEFAULT '',
+			date_posted DATETIME,
+			tags        TEXT NOT NULL DEFAULT '[]',
+			series      TEXT NOT NULL DEFAULT ''
+		);
+		CREATE VIRTUAL TABLE IF NOT EXISTS posts_fts USING fts5(
+			slug UNINDEXED, title, body, content='posts', content_rowid='rowid'
+		);
+		CREATE TRIGGER IF NOT EXISTS posts_ai AFTER INSERT ON posts BEGIN
+			INSERT INTO posts_fts(rowid, slug, title, body) VALUES (new.rowid, new.slug, new.title, new.body);
+		END;
+		CREATE TRIGGER IF NOT EXISTS posts_ad AFTER DELETE ON posts BEGIN
+			INSERT INTO posts_fts(posts_fts, rowid, slug, title, body) VALUES ('delete', old.rowid, old.slug, old.title, old.body);
+		END;
+		CREATE TRIGGER IF NOT EXISTS posts_au AFTER UPDATE ON posts BEGIN
+			INSERT INTO posts_fts(posts_fts, rowid, slug, title, body) VALUES ('delete', old.rowid, old.slug, old.title, old.body);
+			INSERT INTO posts_fts(rowid, slug, title, body) VALUES (new.rowid, new.slug, new.title, new.body);
+		END;
+		CREATE TABLE IF NOT EXISTS post_tags (
+			slug TEXT NOT NULL,
+			tag  TEXT NOT NULL
+		);
+		CREATE INDEX IF NOT EXISTS post_tags_tag_idx ON post_tags(tag);
+		CREATE INDEX IF NOT EXISTS posts_series_idx ON posts(series);
+	`)
+	if err != nil {
+		return fmt.Errorf("could not migrate sqlite schema: %v", err)
+	}
+	return nil
+}
+
+// Close closes the underlying database handle.
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+// Upsert inserts or replaces the row for slug, along with its entries in the
+// post_tags index, kept in the same transaction so they can't drift apart.
+func (s *SQLiteStore) Upsert(post Post, slug string) error {
+	tagsJSON, err := json.Marshal(post.Tags)
+	if err != nil {
+		return fmt.Errorf("could not marshal tags: %v", err)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(
+		`INSERT INTO posts (slug, title, author, body, date_posted, tags, series) VALUES (?, ?, ?, ?, ?, ?, ?)
+		 ON CONFLICT(slug) DO UPDATE SET title=excluded.title, author=excluded.author, body=excluded.body, date_posted=excluded.date_posted, tags=excluded.tags, series=excluded.series`,
+		slug, post.Title, post.Author, post.Body, post.DatePosted, string(tagsJSON), post.Series,
+	)
+	if err != nil {
+		return fmt.Errorf("could not upsert post: %v", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM post_tags WHERE slug = ?`, slug); err != nil {
+		return fmt.Errorf("could not clear tag index: %v", err)
+	}
+	for _, tag := range post.Tags {
+		if tag == "" {
+			continue
+		}
+		if _, err := tx.Exec(`INSERT INTO post_tags (slug, tag) VALUES (?, ?)`, slug, tag); err != nil {
+			return fmt.Errorf("could not index tag %q: %v", tag, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// Get returns the post stored under slug.
+func (s *SQLiteStore) Get(slug string) (*Post, error) {
+	row := s.db.QueryRow(`SELECT slug, title, author, body, date_posted, tags, series FROM posts WHERE slug = ?`, slug)
+	return scanPostRows(row)
+}
+
+// List returns every post, keyed by slug.
+func (s *SQLiteStore) List() (PostMap, error) {
+	rows, err := s.db.Query(`SELECT slug, title, author, body, date_posted, tags, series FROM posts`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := PostMap{}
+	for rows.Next() {
+		post, err := scanPostRows(rows)
+		if err != nil {
+			return nil, err
+		}
+		results[post.Slug] = *post
+	}
+	return results, rows.Err()
+}
+
+// Delete removes the post stored under slug, along with its entries in the
+// post_tags index.
+func (s *SQLiteStore) Delete(slug string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM posts WHERE slug = ?`, slug); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM post_tags WHERE slug = ?`, slug); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// PostsByTag returns every post tagged with tag, via the post_tags index.
+func (s *SQLiteStore) PostsByTag(tag string) ([]Post, error) {
+	rows, err := s.db.Query(
+		`SELECT posts.slug, posts.title, posts.author, posts.body, posts.date_posted, posts.tags, posts.series
+		 FROM posts JOIN post_tags ON post_tags.slug = posts.slug WHERE post_tags.tag = ?`,
+		tag,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not query posts by tag: %v", err)
+	}
+	return scanPostRowsAll(rows)
+}
+
+// PostsBySeries returns every post in series, oldest first.
+func (s *SQLiteStore) PostsBySeries(series string) ([]Post, error) {
+	rows, err := s.db.Query(
+		`SELECT slug, title, author, body, date_posted, tags, series FROM posts WHERE series = ? ORDER BY date_posted ASC`,
+		series,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not query posts by series: %v", err)
+	}
+	return scanPostRowsAll(rows)
+}
+
+// Search ranks posts by SQLite FTS5's built-in BM25 relevance function.
+func (s *SQLiteStore) Search(query string, limit int) ([]SearchHit, error) {
+	rows, err := s.db.Query(
+		`SELECT slug, bm25(posts_fts) FROM posts_fts WHERE posts_fts MATCH ? ORDER BY bm25(posts_fts) LIMIT ?`,
+		query, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not search posts: %v", err)
+	}
+	defer rows.Close()
+
+	var hits []SearchHit
+	for rows.Next() {
+		var slug string
+		var rank float64
+		if err := rows.Scan(&slug, &rank); err != nil {
+			return nil, err
+		}
+		// bm25() returns lower-is-better; invert so higher scores win like
+		// the bolt-backed search package does.
+		hits = append(hits, SearchHit{Slug: slug, Score: -rank})
+	}
+	return hits, rows.Err()
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanPostRows(row rowScanner) (*Post, error) {
+	post := &Post{}
+	var datePosted sql.NullTime
+	var tagsJSON string
+	if err := row.Scan(&post.Slug, &post.Title, &post.Author, &post.Body, &datePosted, &tagsJSON, &post.Series); err != nil {
+		return nil, err
+	}
+	if datePosted.Valid {
+		post.DatePosted = datePosted.Time
+	}
+	if tagsJSON != "" {
+		if err := json.Unmarshal([]byte(tagsJSON), &post.Tags); err != nil {
+			return nil, fmt.Errorf("could not unmarshal tags: %v", err)
+		}
+	}
+	return post, nil
+}
+
+// scanPostRowsAll drains a *sql.Rows of posts, closing it when done.
+func scanPostRowsAll(rows *sql.Rows) ([]Post, error) {
+	defer rows.Close()
+	var posts []Post
+	for rows.Next() {
+		post, err := scanPostRows(rows)
+		if err != nil {
+			return nil, err
+		}
+		posts = append(posts, *post)
+	}
+	return posts, rows.Err()
+}
@@ -0,0 +1,61 @@
+// Package store defines the persistence boundary go-tiny-blog's handlers are
+// written against, plus the backends that implement it: BoltDB (the
+// original storage), flat markdown files with YAML front matter, and SQLite
+// with FTS5 full-text search.
+package store
+
+import "time"
+
+// Post is the data for a single blog post, persisted by whichever PostStore
+// backend is configured.
+type Post struct {
+	Author     string    `json:"author,omitempty" yaml:"author,omitempty"`
+	Body       string    `json:"body,omitempty" yaml:"-"`
+	DatePosted time.Time `json:"datePosted,omitempty" yaml:"datePosted,omitempty"`
+	Title      string    `json:"title,omitempty" yaml:"title,omitempty"`
+	Slug       string    `json:"slug,omitempty" yaml:"slug,omitempty"`
+	Tags       []string  `json:"tags,omitempty" yaml:"tags,omitempty"`
+	Series     string    `json:"series,omitempty" yaml:"series,omitempty"`
+}
+
+// PostMap is a map of posts with the slug as the key.
+type PostMap map[string]Post
+
+// PostStore is the persistence boundary go-tiny-blog's handlers are written
+// against, so the backend can be swapped without touching handler code.
+type PostStore interface {
+	// Upsert writes post under slug, creating or overwriting it.
+	Upsert(post Post, slug string) error
+	// Get returns the post stored under slug.
+	Get(slug string) (*Post, error)
+	// List returns every post, keyed by slug.
+	List() (PostMap, error)
+	// Delete removes the post stored under slug.
+	Delete(slug string) error
+}
+
+// SearchHit is a single ranked result from a Searcher.
+type SearchHit struct {
+	Slug  string
+	Score float64
+}
+
+// Searcher is implemented by backends that can rank their own posts by
+// relevance to a query. Not every PostStore can do this cheaply (the flat
+// file backend, notably, can't), so handlers must type-assert for it rather
+// than relying on it being present.
+type Searcher interface {
+	Search(query string, limit int) ([]SearchHit, error)
+}
+
+// TagIndex is implemented by backends that can look posts up by tag or
+// series. Every backend in this package implements it, but it's kept
+// optional (like Searcher) so handlers that only need a PostStore aren't
+// forced to depend on it.
+type TagIndex interface {
+	// PostsByTag returns every post tagged with tag.
+	PostsByTag(tag string) ([]Post, error)
+	// PostsBySeries returns every post in series, ordered oldest first so
+	// callers can walk it for prev/next navigation.
+	PostsBySeries(series string) ([]Post, error)
+}
@@ -0,0 +1,162 @@
+// Package taxonomy maintains the TAGS and SERIES secondary indexes over
+// posts, stored transactionally alongside the posts themselves so they
+// never drift apart.
+package taxonomy
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+// Entry is the subset of a post's fields the taxonomy index needs.
+type Entry struct {
+	Tags       []string
+	Series     string
+	DatePosted time.Time
+}
+
+// ReindexPost updates the TAGS and SERIES indexes for slug inside tx: it
+// removes old's associations and adds next's, so a post that changes tags
+// or series on edit doesn't leave stale entries behind. Calling it with a
+// zero-value old (e.g. for a brand new post) is safe.
+func ReindexPost(tx *bolt.Tx, slug string, old, next Entry) error {
+	if err := removeAssociations(tx, slug, old); err != nil {
+		return err
+	}
+	return addAssociations(tx, slug, next)
+}
+
+// RemovePost removes all of entry's tag/series associations, e.g. when the
+// post itself is deleted.
+func RemovePost(tx *bolt.Tx, slug string, entry Entry) error {
+	return removeAssociations(tx, slug, entry)
+}
+
+// PostsByTag returns the slugs of every post tagged with tag.
+func PostsByTag(db *bolt.DB, tag string) ([]string, error) {
+	var slugs []string
+	err := db.View(func(tx *bolt.Tx) error {
+		b, err := bucket(tx, tagsBucketName)
+		if err != nil {
+			return err
+		}
+		prefix := []byte(tag + "\x00")
+		c := b.Cursor()
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			slugs = append(slugs, string(v))
+		}
+		return nil
+	})
+	return slugs, err
+}
+
+// PostsBySeries returns the slugs of every post in series, ordered oldest
+// first. The SERIES bucket's keys embed each post's DatePosted, so a plain
+// prefix scan already returns them in chronological order.
+func PostsBySeries(db *bolt.DB, series string) ([]string, error) {
+	var slugs []string
+	err := db.View(func(tx *bolt.Tx) error {
+		b, err := bucket(tx, seriesBucketName)
+		if err != nil {
+			return err
+		}
+		prefix := []byte(series + "\x00")
+		c := b.Cursor()
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			slugs = append(slugs, string(v))
+		}
+		return nil
+	})
+	return slugs, err
+}
+
+const tagsBucketName = "TAGS"
+const seriesBucketName = "SERIES"
+
+// CreateBuckets idempotently creates the TAGS and SERIES buckets. Called
+// alongside the other bucket setup for a BoltDB-backed PostStore.
+func CreateBuckets(tx *bolt.Tx) error {
+	root := tx.Bucket([]byte("BLOG"))
+	if _, err := root.CreateBucketIfNotExists([]byte(tagsBucketName)); err != nil {
+		return fmt.Errorf("could not create tags bucket: %v", err)
+	}
+	if _, err := root.CreateBucketIfNotExists([]byte(seriesBucketName)); err != nil {
+		return fmt.Errorf("could not create series bucket: %v", err)
+	}
+	return nil
+}
+
+func removeAssociations(tx *bolt.Tx, slug string, entry Entry) error {
+	if len(entry.Tags) > 0 {
+		b, err := bucket(tx, tagsBucketName)
+		if err != nil {
+			return err
+		}
+		for _, tag := range entry.Tags {
+			if tag == "" {
+				continue
+			}
+			if err := b.Delete(tagKey(tag, slug)); err != nil {
+				return err
+			}
+		}
+	}
+	if entry.Series != "" {
+		b, err := bucket(tx, seriesBucketName)
+		if err != nil {
+			return err
+		}
+		if err := b.Delete(seriesKey(entry.Series, entry.DatePosted, slug)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func addAssociations(tx *bolt.Tx, slug string, entry Entry) error {
+	if len(entry.Tags) > 0 {
+		b, err := bucket(tx, tagsBucketName)
+		if err != nil {
+			return err
+		}
+		for _, tag := range entry.Tags {
+			if tag == "" {
+				continue
+			}
+			if err := b.Put(tagKey(tag, slug), []byte(slug)); err != nil {
+				return fmt.Errorf("taxonomy: could not index tag %q: %v", tag, err)
+			}
+		}
+	}
+	if entry.Series != "" {
+		b, err := bucket(tx, seriesBucketName)
+		if err != nil {
+			return err
+		}
+		if err := b.Put(seriesKey(entry.Series, entry.DatePosted, slug), []byte(slug)); err != nil {
+			return fmt.Errorf("taxonomy: could not index series %q: %v", entry.Series, err)
+		}
+	}
+	return nil
+}
+
+func bucket(tx *bolt.Tx, name string) (*bolt.Bucket, error) {
+	b := tx.Bucket([]byte("BLOG")).Bucket([]byte(name))
+	if b == nil {
+		return nil, fmt.Errorf("taxonomy: %s bucket not found", name)
+	}
+	return b, nil
+}
+
+func tagKey(tag, slug string) []byte {
+	return []byte(tag + "\x00" + slug)
+}
+
+// seriesKey embeds DatePosted as a fixed-width, zero-padded nanosecond
+// timestamp so that lexicographic key order matches chronological order.
+func seriesKey(series string, datePosted time.Time, slug string) []byte {
+	return []byte(fmt.Sprintf("%s\x00%019d\x00%s", series, datePosted.UnixNano(), slug))
+}